@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// size of the binary encoding of StatusResponse:
+// state (1) + battery percentage (2) + battery voltage (8) + position (4) + timestamp (8)
+const statusBinarySize = 23
+
+var binaryStates = []State{"", Locked, Unlocked, Moved}
+
+// zero time is encoded as math.MinInt64, so the earliest encodable time is the next nanosecond.
+var (
+	minBinaryTimestamp = time.Unix(0, math.MinInt64+1)
+	maxBinaryTimestamp = time.Unix(0, math.MaxInt64)
+)
+
+// EncodeStatusBinary encodes the status into a compact fixed-layout binary.
+// Only Status, BatteryPercentage, BatteryVoltage, Position and Timestamp are encoded, and Timestamp loses its location.
+// An error is returned for unknown states and values which don't fit in the layout
+// instead of encoding them lossily.
+func EncodeStatusBinary(s *StatusResponse) ([]byte, error) {
+	state := -1
+	for i, known := range binaryStates {
+		if s.Status == known {
+			state = i
+		}
+	}
+	if state < 0 {
+		return nil, fmt.Errorf("sesame: EncodeStatusBinary: %w", &ValidationError{Field: "Status", Reason: fmt.Sprintf("unknown state %q", s.Status)})
+	}
+	if s.BatteryPercentage < math.MinInt16 || math.MaxInt16 < s.BatteryPercentage {
+		return nil, fmt.Errorf("sesame: EncodeStatusBinary: %w", &ValidationError{Field: "BatteryPercentage", Reason: "must fit in 16 bits"})
+	}
+	if s.Position < math.MinInt32 || math.MaxInt32 < s.Position {
+		return nil, fmt.Errorf("sesame: EncodeStatusBinary: %w", &ValidationError{Field: "Position", Reason: "must fit in 32 bits"})
+	}
+
+	timestamp := int64(math.MinInt64) // zero time
+	if !s.Timestamp.IsZero() {
+		if s.Timestamp.Before(minBinaryTimestamp) || s.Timestamp.After(maxBinaryTimestamp) {
+			return nil, fmt.Errorf("sesame: EncodeStatusBinary: %w", &ValidationError{Field: "Timestamp", Reason: "must be between 1678 and 2262"})
+		}
+		timestamp = s.Timestamp.UnixNano()
+	}
+
+	b := make([]byte, statusBinarySize)
+	b[0] = byte(state)
+	binary.BigEndian.PutUint16(b[1:3], uint16(int16(s.BatteryPercentage)))
+	binary.BigEndian.PutUint64(b[3:11], math.Float64bits(s.BatteryVoltage))
+	binary.BigEndian.PutUint32(b[11:15], uint32(int32(s.Position)))
+	binary.BigEndian.PutUint64(b[15:23], uint64(timestamp))
+	return b, nil
+}
+
+// DecodeStatusBinary decodes the binary encoded by EncodeStatusBinary.
+func DecodeStatusBinary(b []byte) (*StatusResponse, error) {
+	if len(b) != statusBinarySize {
+		return nil, fmt.Errorf("sesame: DecodeStatusBinary: invalid binary length %d", len(b))
+	}
+	if int(b[0]) >= len(binaryStates) {
+		return nil, fmt.Errorf("sesame: DecodeStatusBinary: invalid state %d", b[0])
+	}
+
+	var timestamp time.Time
+	if nsec := int64(binary.BigEndian.Uint64(b[15:23])); nsec != math.MinInt64 {
+		timestamp = time.Unix(0, nsec)
+	}
+
+	return &StatusResponse{
+		Status:            binaryStates[b[0]],
+		BatteryPercentage: int(int16(binary.BigEndian.Uint16(b[1:3]))),
+		BatteryVoltage:    math.Float64frombits(binary.BigEndian.Uint64(b[3:11])),
+		Position:          int(int32(binary.BigEndian.Uint32(b[11:15]))),
+		Timestamp:         timestamp,
+	}, nil
+}
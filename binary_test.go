@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStatusBinaryRoundTrip(t *testing.T) {
+	tests := []*StatusResponse{
+		{},
+		{Status: Locked, BatteryPercentage: 100, BatteryVoltage: 5.85, Position: 11, Timestamp: time.Unix(1600000000, 123)},
+		{Status: Moved, BatteryPercentage: -1, BatteryVoltage: math.Inf(1), Position: math.MinInt32, Timestamp: time.Unix(0, math.MinInt64+1)},
+		{Status: Unlocked, BatteryPercentage: math.MaxInt16, Position: math.MaxInt32, Timestamp: time.Unix(0, math.MaxInt64)},
+	}
+	for _, want := range tests {
+		b, err := EncodeStatusBinary(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := DecodeStatusBinary(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected status:\n  got:  %+v\n  want: %+v", got, want)
+		}
+	}
+}
+
+func TestEncodeStatusBinaryRejectsLossyValues(t *testing.T) {
+	tests := []struct {
+		field  string
+		status *StatusResponse
+	}{
+		{"Status", &StatusResponse{Status: "calibrating"}},
+		{"BatteryPercentage", &StatusResponse{BatteryPercentage: math.MaxInt16 + 1}},
+		{"Position", &StatusResponse{Position: math.MinInt32 - 1}},
+		{"Timestamp", &StatusResponse{Timestamp: time.Date(1600, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		{"Timestamp", &StatusResponse{Timestamp: time.Date(2300, 1, 1, 0, 0, 0, 0, time.UTC)}},
+	}
+	for _, tt := range tests {
+		var verr *ValidationError
+		if _, err := EncodeStatusBinary(tt.status); !errors.As(err, &verr) || verr.Field != tt.field {
+			t.Errorf("ValidationError for %s is expected but got: %v", tt.field, err)
+		}
+	}
+}
+
+func TestDecodeStatusBinaryRejectsInvalidBinary(t *testing.T) {
+	b, err := EncodeStatusBinary(&StatusResponse{Status: Locked})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeStatusBinary(b[:len(b)-1]); err == nil {
+		t.Error("error is expected for short binary but got nil")
+	}
+	b[0] = byte(len(binaryStates))
+	if _, err := DecodeStatusBinary(b); err == nil {
+		t.Error("error is expected for invalid state but got nil")
+	}
+}
+
+// StatusResponse must be encoded by gob with all fields, not by the compact binary.
+func TestStatusGob(t *testing.T) {
+	wifi, autoLock := true, 30
+	want := StatusResponse{
+		Status:          Locked,
+		MechStatus:      &MechStatus{InLockRange: true},
+		WiFiModuleState: &wifi,
+		AutoLockSecond:  &autoLock,
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(want); err != nil {
+		t.Fatal(err)
+	}
+	var got StatusResponse
+	if err := gob.NewDecoder(&buf).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected status:\n  got:  %+v\n  want: %+v", got, want)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxStatusCacheFetch bounds the shared request, which is not canceled by any caller.
+const maxStatusCacheFetch = time.Minute
+
+type statusCacheEntry struct {
+	done      chan struct{}
+	status    *StatusResponse
+	err       error
+	startedAt time.Time
+	fetchedAt time.Time
+}
+
+// cachedStatus returns the cached status if it's fresh, otherwise fetches it.
+// Concurrent calls share one request, which is made with the values of the first caller's ctx
+// but not canceled by any caller, so that a canceled caller doesn't fail the others.
+// A request in flight longer than StatusCacheTTL is not shared anymore, so that a hung request
+// doesn't block the device. Errors are not cached.
+func (c *Client) cachedStatus(ctx context.Context, uuid string) (*StatusResponse, error) {
+	if err := c.validate(ctx, uuid); err != nil {
+		return nil, fmt.Errorf("sesame: Status: %w", err)
+	}
+
+	// UUIDs are compared as they are requested
+	requested := uuid
+	if c.AutoUppercaseUUID {
+		requested = strings.ToUpper(uuid)
+	}
+	// the API key is included in the key since it may differ per context
+	key := c.apiKey(ctx) + "\x00" + requested
+
+	c.cacheMu.Lock()
+	if c.statusCache == nil {
+		c.statusCache = map[string]*statusCacheEntry{}
+	}
+	entry, ok := c.statusCache[key]
+	if ok {
+		select {
+		case <-entry.done:
+			ok = c.fresh(entry)
+		default:
+			// in flight
+			ok = c.now().Sub(entry.startedAt) < c.StatusCacheTTL
+		}
+	}
+	if !ok {
+		c.evictStatusCache()
+		entry = &statusCacheEntry{done: make(chan struct{}), startedAt: c.now()}
+		c.statusCache[key] = entry
+		go c.fetchStatusCacheEntry(context.WithoutCancel(ctx), key, uuid, entry)
+	}
+	c.cacheMu.Unlock()
+
+	select {
+	case <-entry.done:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("sesame: Status: %w", ctx.Err())
+	}
+
+	if entry.err != nil {
+		return nil, entry.err
+	}
+	return copyStatus(entry.status), nil
+}
+
+func (c *Client) fetchStatusCacheEntry(ctx context.Context, key, uuid string, entry *statusCacheEntry) {
+	ctx, cancel := context.WithTimeout(ctx, maxStatusCacheFetch)
+	defer cancel()
+	status, err := c.status(ctx, uuid)
+
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	entry.status, entry.err = status, err
+	entry.fetchedAt = c.now()
+	if err != nil && c.statusCache[key] == entry {
+		delete(c.statusCache, key)
+	}
+	close(entry.done)
+}
+
+// fresh reports whether the fetched entry is still valid.
+func (c *Client) fresh(entry *statusCacheEntry) bool {
+	return entry.err == nil && c.now().Sub(entry.fetchedAt) < c.StatusCacheTTL
+}
+
+// evictStatusCache deletes the expired entries. c.cacheMu must be held.
+func (c *Client) evictStatusCache() {
+	for key, entry := range c.statusCache {
+		select {
+		case <-entry.done:
+			if !c.fresh(entry) {
+				delete(c.statusCache, key)
+			}
+		default:
+		}
+	}
+}
+
+// copyStatus returns a deep copy of s, so that callers can't modify the cached status.
+func copyStatus(s *StatusResponse) *StatusResponse {
+	status := *s
+	if s.MechStatus != nil {
+		v := *s.MechStatus
+		status.MechStatus = &v
+	}
+	if s.WiFiModuleState != nil {
+		v := *s.WiFiModuleState
+		status.WiFiModuleState = &v
+	}
+	if s.AutoLockSecond != nil {
+		v := *s.AutoLockSecond
+		status.AutoLockSecond = &v
+	}
+	if s.Raw != nil {
+		status.Raw = append(json.RawMessage(nil), s.Raw...)
+	}
+	return &status
+}
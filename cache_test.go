@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCachedStatusSharesRequest(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		_, _ = io.WriteString(w, `{"batteryPercentage": 42, "mechStatus": {"isInLockRange": true}, "wm2State": true, "autoLockSecond": 30}`)
+	}))
+	c.StatusCacheTTL = time.Minute
+	c.AutoUppercaseUUID = true
+
+	// the first caller gives up, which must not fail the others
+	canceled, cancel := context.WithCancel(context.Background())
+	canceledErr := make(chan error)
+	go func() {
+		_, err := c.Status(canceled, "device")
+		canceledErr <- err
+	}()
+	for requests.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-canceledErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("context.Canceled is expected but got: %v", err)
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	statuses := make([]*StatusResponse, n)
+	errs := make([]error, n)
+	for i := range n {
+		uuid := "device"
+		if i%2 == 0 {
+			uuid = "DEVICE"
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i], errs[i] = c.Status(context.Background(), uuid)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := requests.Load(); got != 1 {
+		t.Errorf("only 1 request is expected but got %d", got)
+	}
+	for i := range n {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if statuses[i].BatteryPercentage != 42 {
+			t.Errorf("unexpected battery percentage: %d", statuses[i].BatteryPercentage)
+		}
+	}
+
+	// modifying the returned status must not affect the cache
+	statuses[0].MechStatus.InLockRange = false
+	*statuses[0].WiFiModuleState = false
+	*statuses[0].AutoLockSecond = 0
+	statuses[0].Raw[0] = 'x'
+	status, err := c.Status(context.Background(), "DEVICE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.MechStatus.InLockRange || !*status.WiFiModuleState || *status.AutoLockSecond != 30 || status.Raw[0] != '{' {
+		t.Errorf("cached status is modified: %+v", status)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("cached status should be returned but requested %d times", got)
+	}
+}
+
+func TestCachedStatusEviction(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	var fail atomic.Bool
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = io.WriteString(w, `{"batteryPercentage": 42}`)
+	}))
+	c.StatusCacheTTL = time.Minute
+	c.Now = func() time.Time { return now }
+
+	if _, err := c.Status(context.Background(), "A"); err != nil {
+		t.Fatal(err)
+	}
+
+	fail.Store(true)
+	if _, err := c.Status(context.Background(), "B"); err == nil {
+		t.Fatal("error is expected but got nil")
+	}
+	if _, ok := c.statusCache[testAPIKey+"\x00B"]; ok {
+		t.Error("failed entry should be deleted")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := c.Status(context.Background(), "C"); err == nil {
+		t.Fatal("error is expected but got nil")
+	}
+	if len(c.statusCache) != 0 {
+		t.Errorf("expired entries should be deleted: %v", c.statusCache)
+	}
+}
+
+func TestCachedStatusHungRequest(t *testing.T) {
+	var mu sync.Mutex
+	now := time.Unix(1600000000, 0)
+	var requests atomic.Int32
+	release := make(chan struct{})
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			// the first request hangs
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		_, _ = io.WriteString(w, `{"batteryPercentage": 42}`)
+	}))
+	t.Cleanup(func() { close(release) })
+	c.Now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	c.StatusCacheTTL = time.Minute
+
+	status := func() (*StatusResponse, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		return c.Status(ctx, "DEVICE")
+	}
+
+	// callers with deadlines are not blocked by the hung request
+	for range 2 {
+		if _, err := status(); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("context.DeadlineExceeded is expected but got: %v", err)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("the request in flight should be shared but requested %d times", got)
+	}
+
+	// the hung request is not shared anymore after StatusCacheTTL
+	mu.Lock()
+	now = now.Add(time.Minute)
+	mu.Unlock()
+	got, err := status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.BatteryPercentage != 42 {
+		t.Errorf("unexpected battery percentage: %d", got.BatteryPercentage)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("a new request is expected but requested %d times", got)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// ChangedFields is a bitmask of the status fields which have changed.
+type ChangedFields uint
+
+const (
+	StateChanged ChangedFields = 1 << iota
+	BatteryChanged
+	PositionChanged
+)
+
+// Has reports whether f contains all of the given fields.
+func (f ChangedFields) Has(fields ChangedFields) bool { return f&fields == fields }
+
+// ChangesSince returns the fields which have changed since prev.
+// The timestamp is not taken into account. Every field is reported as changed when prev is nil.
+// The API doesn't provide any delta endpoint, so this is useful to emit only the changes while polling.
+func (s *StatusResponse) ChangesSince(prev *StatusResponse) ChangedFields {
+	if prev == nil {
+		return StateChanged | BatteryChanged | PositionChanged
+	}
+
+	var changed ChangedFields
+	if s.Status != prev.Status {
+		changed |= StateChanged
+	}
+	if s.BatteryPercentage != prev.BatteryPercentage || s.BatteryVoltage != prev.BatteryVoltage {
+		changed |= BatteryChanged
+	}
+	if s.Position != prev.Position {
+		changed |= PositionChanged
+	}
+	return changed
+}
+
+// StatusChangedSince fetches the current status of the device and reports whether
+// the state, battery or position has changed since ref. The timestamp is not taken into account.
+func (c *Client) StatusChangedSince(ctx context.Context, uuid string, ref *StatusResponse) (changed bool, current *StatusResponse, err error) {
+	current, err = c.Status(ctx, uuid)
+	if err != nil {
+		return false, nil, fmt.Errorf("sesame: StatusChangedSince: %w", err)
+	}
+	return current.ChangesSince(ref) != 0, current, nil
+}
+
+// Fingerprint returns a stable hash of the state, battery and position of the status.
+// The timestamp is not taken into account, so the fingerprint can be used to skip redundant processing.
+func (s *StatusResponse) Fingerprint() string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%g|%d", s.Status, s.BatteryPercentage, s.BatteryVoltage, s.Position)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestChangesSince(t *testing.T) {
+	prev := &StatusResponse{Status: Locked, BatteryPercentage: 80, BatteryVoltage: 5.8, Position: 11, Timestamp: time.Unix(1600000000, 0)}
+
+	tests := []struct {
+		name    string
+		current StatusResponse
+		want    ChangedFields
+	}{
+		{"nothing", *prev, 0},
+		{"timestamp only", StatusResponse{Status: Locked, BatteryPercentage: 80, BatteryVoltage: 5.8, Position: 11, Timestamp: time.Unix(1600000060, 0)}, 0},
+		{"battery only", StatusResponse{Status: Locked, BatteryPercentage: 79, BatteryVoltage: 5.8, Position: 11}, BatteryChanged},
+		{"voltage only", StatusResponse{Status: Locked, BatteryPercentage: 80, BatteryVoltage: 5.7, Position: 11}, BatteryChanged},
+		{"state and position", StatusResponse{Status: Unlocked, BatteryPercentage: 80, BatteryVoltage: 5.8, Position: 300}, StateChanged | PositionChanged},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.current.ChangesSince(prev); got != tt.want {
+				t.Errorf("unexpected changes: %b != %b", got, tt.want)
+			}
+		})
+	}
+
+	if got := prev.ChangesSince(nil); !got.Has(StateChanged | BatteryChanged | PositionChanged) {
+		t.Errorf("every field should be changed since nil: %b", got)
+	}
+}
+
+func TestStatusChangedSince(t *testing.T) {
+	ref := &StatusResponse{Status: Locked, BatteryPercentage: 80, Position: 11}
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"unchanged", `{"CHSesame2Status": "locked", "batteryPercentage": 80, "position": 11, "timestamp": "2020-01-01T00:00:00Z"}`, false},
+		{"changed", `{"CHSesame2Status": "unlocked", "batteryPercentage": 80, "position": 400}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.WriteString(w, tt.body)
+			}))
+
+			changed, current, err := c.StatusChangedSince(context.Background(), "DEVICE", ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed != tt.want {
+				t.Errorf("unexpected changed: %t != %t", changed, tt.want)
+			}
+			if current == nil || string(current.Raw) != tt.body {
+				t.Errorf("current status should be returned: %+v", current)
+			}
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	base := StatusResponse{Status: Locked, BatteryPercentage: 80, BatteryVoltage: 5.8, Position: 11, Timestamp: time.Unix(1600000000, 0)}
+	fingerprint := base.Fingerprint()
+	if fingerprint != base.Fingerprint() {
+		t.Fatal("fingerprint should be stable")
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*StatusResponse)
+		same   bool
+	}{
+		{"timestamp", func(s *StatusResponse) { s.Timestamp = s.Timestamp.Add(time.Hour) }, true},
+		{"state", func(s *StatusResponse) { s.Status = Unlocked }, false},
+		{"battery", func(s *StatusResponse) { s.BatteryPercentage = 79 }, false},
+		{"voltage", func(s *StatusResponse) { s.BatteryVoltage = 5.7 }, false},
+		{"position", func(s *StatusResponse) { s.Position = 300 }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := base
+			tt.modify(&status)
+			if got := status.Fingerprint() == fingerprint; got != tt.same {
+				t.Errorf("unexpected fingerprint equality: %t != %t", got, tt.same)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import "crypto/aes"
+
+// cmac calculates AES-CMAC defined in RFC 4493.
+func cmac(key, msg []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// generate subkeys
+	k1 := make([]byte, aes.BlockSize)
+	block.Encrypt(k1, k1)
+	shiftXOR(k1, k1)
+	k2 := make([]byte, aes.BlockSize)
+	shiftXOR(k2, k1)
+
+	n := (len(msg) + aes.BlockSize - 1) / aes.BlockSize
+	last := make([]byte, aes.BlockSize)
+	if n > 0 && len(msg)%aes.BlockSize == 0 {
+		xor(last, msg[(n-1)*aes.BlockSize:], k1)
+	} else {
+		if n == 0 {
+			n = 1
+		}
+		rest := msg[(n-1)*aes.BlockSize:]
+		copy(last, rest)
+		last[len(rest)] = 0x80
+		xor(last, last, k2)
+	}
+
+	mac := make([]byte, aes.BlockSize)
+	for i := 0; i < n-1; i++ {
+		xor(mac, mac, msg[i*aes.BlockSize:(i+1)*aes.BlockSize])
+		block.Encrypt(mac, mac)
+	}
+	xor(mac, mac, last)
+	block.Encrypt(mac, mac)
+
+	return mac, nil
+}
+
+// shiftXOR sets dst to src shifted left by one bit,
+// then XORed with Rb when the most significant bit of src is set.
+func shiftXOR(dst, src []byte) {
+	msb := src[0] >> 7
+	for i := 0; i < len(src)-1; i++ {
+		dst[i] = src[i]<<1 | src[i+1]>>7
+	}
+	dst[len(src)-1] = src[len(src)-1] << 1
+	dst[len(src)-1] ^= 0x87 * msb
+}
+
+// xor sets dst[i] = a[i] ^ b[i] for each i of dst.
+func xor(dst, a, b []byte) {
+	for i := range dst {
+		dst[i] = a[i] ^ b[i]
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// test vectors are from RFC 4493 section 4
+func TestCMAC(t *testing.T) {
+	key := mustDecodeHex(t, "2b7e151628aed2a6abf7158809cf4f3c")
+	msg := mustDecodeHex(t, "6bc1bee22e409f96e93d7e117393172a"+
+		"ae2d8a571e03ac9c9eb76fac45af8e51"+
+		"30c81c46a35ce411e5fbc1191a0a52ef"+
+		"f69f2445df4f9b17ad2b417be66c3710")
+
+	tests := []struct {
+		name string
+		len  int
+		want string
+	}{
+		{"Example 1", 0, "bb1d6929e95937287fa37d129b756746"},
+		{"Example 2", 16, "070a16b46b4d4144f79bdd9dd04a287c"},
+		{"Example 3", 40, "dfa66747de9ae63030ca32611497c827"},
+		{"Example 4", 64, "51f0bebf7e3b9d92fc49741779363cfe"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mac, err := cmac(key, msg[:tt.len])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(mac); got != tt.want {
+				t.Errorf("unexpected MAC: %s != %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	key := mustDecodeHex(t, "000102030405060708090a0b0c0d0e0f")
+
+	got, err := sign(key, time.Unix(0x12345678, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the message is the bytes 1 to 3 of the little-endian unix time, i.e. 78 56 34 12 without the first byte
+	mac, err := cmac(key, []byte{0x56, 0x34, 0x12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := hex.EncodeToString(mac); got != want {
+		t.Errorf("unexpected signature: %s != %s", got, want)
+	}
+	if want := "c36e8692c90c3db71c8abf3d736d6d76"; got != want {
+		t.Errorf("unexpected signature: %s != %s", got, want)
+	}
+
+	if _, err := sign(key[:15], time.Unix(0x12345678, 0)); !errors.Is(err, ErrInvalidSecretKey) {
+		t.Errorf("ErrInvalidSecretKey is expected but got: %v", err)
+	}
+}
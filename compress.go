@@ -0,0 +1,65 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Decompressor returns a reader decoding r.
+// This can be used to support content codings other than gzip
+// without this package depending on their implementations.
+type Decompressor func(r io.Reader) (io.ReadCloser, error)
+
+func (c *Client) acceptEncoding() string {
+	encodings := make([]string, 0, len(c.Decompressors)+1)
+	for encoding := range c.Decompressors {
+		encodings = append(encodings, encoding)
+	}
+	sort.Strings(encodings)
+	if _, ok := c.Decompressors["gzip"]; !ok {
+		encodings = append(encodings, "gzip")
+	}
+	return strings.Join(encodings, ", ")
+}
+
+// decompress replaces the response body with the decoded one according to Content-Encoding.
+func (c *Client) decompress(resp *http.Response) error {
+	encoding := resp.Header.Get("Content-Encoding")
+	if encoding == "" || encoding == "identity" {
+		return nil
+	}
+
+	decompressor, ok := c.Decompressors[encoding]
+	if !ok && encoding == "gzip" {
+		decompressor = func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) }
+		ok = true
+	}
+	if !ok {
+		return fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+
+	r, err := decompressor(resp.Body)
+	if err != nil {
+		return fmt.Errorf("decoding %s response body: %w", encoding, err)
+	}
+
+	resp.Body = &decompressedBody{ReadCloser: r, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	return nil
+}
+
+type decompressedBody struct {
+	io.ReadCloser
+	body io.ReadCloser
+}
+
+func (b *decompressedBody) Close() error {
+	_ = b.ReadCloser.Close()
+	return b.body.Close()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestDecompress(t *testing.T) {
+	const body = `{"batteryPercentage": 42, "CHSesame2Status": "locked"}`
+
+	var gzipped bytes.Buffer
+	zw := gzip.NewWriter(&gzipped)
+	if _, err := io.WriteString(zw, body); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	encoded := map[string][]byte{
+		"gzip":   gzipped.Bytes(),
+		"base64": []byte(base64.StdEncoding.EncodeToString([]byte(body))),
+		"br":     []byte("unsupported"),
+	}
+	decompressors := map[string]Decompressor{
+		"base64": func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(base64.NewDecoder(base64.StdEncoding, r)), nil
+		},
+	}
+
+	tests := []struct {
+		encoding string
+		wantErr  bool
+	}{
+		{encoding: "gzip"},
+		{encoding: "base64"},
+		{encoding: "br", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.encoding, func(t *testing.T) {
+			c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got, want := r.Header.Get("Accept-Encoding"), "base64, gzip"; got != want {
+					t.Errorf("unexpected Accept-Encoding: %s != %s", got, want)
+				}
+				w.Header().Set("Content-Encoding", tt.encoding)
+				_, _ = w.Write(encoded[tt.encoding])
+			}))
+			c.Decompressors = decompressors
+
+			status, err := c.Status(context.Background(), "DEVICE")
+			if tt.wantErr {
+				if err == nil {
+					t.Error("error is expected but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.BatteryPercentage != 42 || status.Status != Locked {
+				t.Errorf("unexpected status: %+v", status)
+			}
+		})
+	}
+}
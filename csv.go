@@ -0,0 +1,29 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// WriteHistoryCSV writes the history records to w as CSV with a header row.
+func WriteHistoryCSV(w io.Writer, pages []HistoryPage) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"record_id", "timestamp", "type", "actor"}); err != nil {
+		return err
+	}
+	for _, page := range pages {
+		record := []string{
+			strconv.Itoa(page.RecordID),
+			page.Timestamp.Format(time.RFC3339),
+			page.Type.String(),
+			page.Actor(),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
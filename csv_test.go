@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteHistoryCSV(t *testing.T) {
+	pages := []HistoryPage{
+		{RecordID: 2, Type: ManualLocked, HistoryTag: "Ym9iLCBqcg==", Timestamp: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)},
+		{RecordID: 1, Type: BLEUnlock, HistoryTag: "YWxpY2U=", Timestamp: time.Date(2020, 1, 1, 9, 30, 0, 0, time.UTC)},
+	}
+
+	var b strings.Builder
+	if err := WriteHistoryCSV(&b, pages); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `record_id,timestamp,type,actor
+2,2020-01-01T12:00:00Z,ManualLocked,"bob, jr"
+1,2020-01-01T09:30:00Z,BLEUnlock,alice
+`
+	if got := b.String(); got != want {
+		t.Errorf("unexpected CSV:\n%s\nwant:\n%s", got, want)
+	}
+}
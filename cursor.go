@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// CursorStore persists the latest synced RecordID of each device.
+// Load should return zero when no cursor is saved for the device.
+type CursorStore interface {
+	Load(uuid string) (int, error)
+	Save(uuid string, recordID int) error
+}
+
+// SyncHistory fetches the history records newer than the cursor loaded from store,
+// then saves the latest RecordID to store as the new cursor.
+// Returned records are ordered newest first, same as History.
+func (c *Client) SyncHistory(ctx context.Context, uuid string, store CursorStore) ([]HistoryPage, error) {
+	if err := c.validate(ctx, uuid); err != nil {
+		return nil, fmt.Errorf("sesame: SyncHistory: %w", err)
+	}
+	if store == nil {
+		return nil, fmt.Errorf("sesame: SyncHistory: %w", &ValidationError{Field: "store", Reason: "must not be nil"})
+	}
+
+	cursor, err := store.Load(uuid)
+	if err != nil {
+		return nil, fmt.Errorf("sesame: SyncHistory: loading cursor: %w", err)
+	}
+
+	var pages []HistoryPage
+	for record, err := range c.HistorySeq(ctx, uuid, c.pageSize()) {
+		if err != nil {
+			return nil, fmt.Errorf("sesame: SyncHistory: %w", err)
+		}
+		if record.RecordID <= cursor {
+			break
+		}
+		pages = append(pages, record)
+	}
+
+	if len(pages) == 0 {
+		return nil, nil
+	}
+
+	latest := cursor
+	for _, record := range pages {
+		if record.RecordID > latest {
+			latest = record.RecordID
+		}
+	}
+	if err := store.Save(uuid, latest); err != nil {
+		return nil, fmt.Errorf("sesame: SyncHistory: saving cursor: %w", err)
+	}
+	return pages, nil
+}
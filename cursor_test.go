@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// memoryCursorStore is a CursorStore keeping cursors in memory.
+type memoryCursorStore map[string]int
+
+func (s memoryCursorStore) Load(uuid string) (int, error) { return s[uuid], nil }
+
+func (s memoryCursorStore) Save(uuid string, recordID int) error {
+	s[uuid] = recordID
+	return nil
+}
+
+func TestSyncHistory(t *testing.T) {
+	records := 5
+	var requests []string
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		newHistoryServer(t, records, 100, &requests).ServeHTTP(w, r)
+	}))
+	c.DefaultPageSize = 2
+	store := memoryCursorStore{}
+
+	recordIDs := func(pages []HistoryPage) []int {
+		var ids []int
+		for _, page := range pages {
+			ids = append(ids, page.RecordID)
+		}
+		return ids
+	}
+
+	pages, err := c.SyncHistory(context.Background(), "DEVICE", store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := recordIDs(pages), []int{5, 4, 3, 2, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected records on the first sync: %v != %v", got, want)
+	}
+	if store["DEVICE"] != 5 {
+		t.Errorf("unexpected cursor after the first sync: %d", store["DEVICE"])
+	}
+
+	records = 8
+	requests = nil
+	pages, err = c.SyncHistory(context.Background(), "DEVICE", store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := recordIDs(pages), []int{8, 7, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected records on the second sync: %v != %v", got, want)
+	}
+	if store["DEVICE"] != 8 {
+		t.Errorf("unexpected cursor after the second sync: %d", store["DEVICE"])
+	}
+	// paging stops at the page containing the cursor
+	if want := []string{"lg=2&page=0", "lg=2&page=1"}; !reflect.DeepEqual(requests, want) {
+		t.Errorf("unexpected requests on the second sync: %v != %v", requests, want)
+	}
+
+	pages, err = c.SyncHistory(context.Background(), "DEVICE", store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 0 || store["DEVICE"] != 8 {
+		t.Errorf("nothing should be synced without new records: %v, cursor %d", recordIDs(pages), store["DEVICE"])
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func FuzzStatusDecode(f *testing.F) {
+	f.Add([]byte(`{"batteryPercentage": 100, "batteryVoltage": 5.85, "position": 11, "CHSesame2Status": "locked", "timestamp": "2020-01-01T00:00:00Z", "mechStatus": {"isInLockRange": true, "isInUnlockRange": false}, "wm2State": true, "autoLockSecond": 30}`))
+	f.Add([]byte(`{"battery": 50, "CHSesame2Status": "moved"}`))
+	f.Add([]byte(`{"battery": 50, "batteryPercentage": 60}`))
+	f.Add([]byte(`{}`))
+	f.Add([]byte(`null`))
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		for _, c := range []*Client{
+			{},
+			{StrictDecoding: true},
+			{StatusFieldAliases: map[string]string{"state": "CHSesame2Status"}},
+		} {
+			var status StatusResponse
+			_ = c.decode(bytes.NewReader(c.resolveStatusAliases(body)), &status)
+		}
+	})
+}
+
+func FuzzHistoryDecode(f *testing.F) {
+	f.Add([]byte(`{"Pages": [{"recordID": 1, "type": 1, "historyTag": "YWxpY2U=", "devicePk": "pk", "timestamp": "2020-01-01T00:00:00Z"}]}`))
+	f.Add([]byte(`{"Pages": []}`))
+	f.Add([]byte(`{"Pages": [{"type": 99, "historyTag": "not base64"}]}`))
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		for _, c := range []*Client{{}, {StrictDecoding: true}} {
+			var hist HistoryResponse
+			if err := c.decode(bytes.NewReader(body), &hist); err != nil {
+				continue
+			}
+			for _, page := range hist.Pages {
+				_ = page.Type.String()
+				_ = page.Actor()
+			}
+		}
+	})
+}
@@ -1,5 +1,5 @@
 module github.com/nasa9084/go-sesame
 
-go 1.16
+go 1.23
 
 require golang.org/x/tools v0.1.3 // indirect
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type Health string
+
+const (
+	Healthy    Health = "healthy"
+	LowBattery Health = "low battery"
+	Jammed     Health = "jammed"
+)
+
+func (health Health) String() string { return string(health) }
+
+const (
+	// battery percentage under this value is considered as low battery.
+	lowBatteryThreshold = 20
+	// the status older than this is considered as stale.
+	staleThreshold = time.Hour
+)
+
+type HealthReport struct {
+	Health  Health
+	Reasons []string
+	// Whether the status is older than an hour. Health is still classified with the stale status.
+	Stale  bool
+	Status *StatusResponse
+}
+
+// CheckHealth reads the status of the device and classifies it as healthy, low battery or jammed.
+// The device is considered as jammed when it reports the position is in neither lock nor unlock range.
+// Jammed takes precedence over low battery.
+func (c *Client) CheckHealth(ctx context.Context, uuid string) (*HealthReport, error) {
+	if err := c.validate(ctx, uuid); err != nil {
+		return nil, fmt.Errorf("sesame: CheckHealth: %w", err)
+	}
+
+	status, err := c.Status(ctx, uuid)
+	if err != nil {
+		return nil, fmt.Errorf("sesame: CheckHealth: %w", err)
+	}
+
+	report := HealthReport{
+		Health: Healthy,
+		Stale:  status.IsProbablyOffline(staleThreshold, c.now()),
+		Status: status,
+	}
+
+	if status.BatteryPercentage < lowBatteryThreshold {
+		report.Health = LowBattery
+		report.Reasons = append(report.Reasons, fmt.Sprintf("battery percentage is %d%%", status.BatteryPercentage))
+	}
+
+	if mech := status.MechStatus; mech != nil && !mech.InLockRange && !mech.InUnlockRange {
+		report.Health = Jammed
+		report.Reasons = append(report.Reasons, fmt.Sprintf("position %d is in neither lock nor unlock range", status.Position))
+	}
+
+	return &report, nil
+}
+
+// CountRecentDriveFailures counts DriveFailed records within the duration before now.
+// A lock repeatedly failing to drive is likely struggling mechanically.
+func CountRecentDriveFailures(pages []HistoryPage, within time.Duration, now time.Time) int {
+	since := now.Add(-within)
+	var count int
+	for _, page := range pages {
+		if page.Type == DriveFailed && !page.Timestamp.Before(since) && !page.Timestamp.After(now) {
+			count++
+		}
+	}
+	return count
+}
+
+// TooManyDriveFailures reports whether the device logged more DriveFailed records than threshold
+// within the duration. History is fetched until the records become older than the duration.
+func (c *Client) TooManyDriveFailures(ctx context.Context, uuid string, within time.Duration, threshold int) (bool, error) {
+	if err := c.validate(ctx, uuid); err != nil {
+		return false, fmt.Errorf("sesame: TooManyDriveFailures: %w", err)
+	}
+	if within <= 0 {
+		return false, fmt.Errorf("sesame: TooManyDriveFailures: %w", &ValidationError{Field: "within", Reason: "must be positive"})
+	}
+	if threshold < 0 {
+		return false, fmt.Errorf("sesame: TooManyDriveFailures: %w", &ValidationError{Field: "threshold", Reason: "must not be negative"})
+	}
+
+	now := c.now()
+	var pages []HistoryPage
+	for page, err := range c.HistorySeq(ctx, uuid, c.pageSize()) {
+		if err != nil {
+			return false, fmt.Errorf("sesame: TooManyDriveFailures: %w", err)
+		}
+		if page.Timestamp.Before(now.Add(-within)) {
+			break
+		}
+		pages = append(pages, page)
+	}
+	return CountRecentDriveFailures(pages, within, now) > threshold, nil
+}
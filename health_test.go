@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCheckHealth(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	tests := []struct {
+		name      string
+		body      string
+		want      Health
+		wantStale bool
+	}{
+		{
+			name: "healthy",
+			body: `{"batteryPercentage": 80, "CHSesame2Status": "locked", "timestamp": %q, "mechStatus": {"isInLockRange": true, "isInUnlockRange": false}}`,
+			want: Healthy,
+		},
+		{
+			name: "moved without mechStatus",
+			body: `{"batteryPercentage": 80, "CHSesame2Status": "moved", "timestamp": %q}`,
+			want: Healthy,
+		},
+		{
+			name: "low battery",
+			body: `{"batteryPercentage": 10, "CHSesame2Status": "unlocked", "timestamp": %q, "mechStatus": {"isInLockRange": false, "isInUnlockRange": true}}`,
+			want: LowBattery,
+		},
+		{
+			name: "jammed",
+			body: `{"batteryPercentage": 10, "CHSesame2Status": "moved", "timestamp": %q, "mechStatus": {"isInLockRange": false, "isInUnlockRange": false}}`,
+			want: Jammed,
+		},
+	}
+	for _, tt := range tests {
+		for _, stale := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/stale=%t", tt.name, stale), func(t *testing.T) {
+				timestamp := now.Add(-time.Minute)
+				if stale {
+					timestamp = now.Add(-2 * time.Hour)
+				}
+				c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprintf(w, tt.body, timestamp.Format(time.RFC3339))
+				}))
+				c.Now = func() time.Time { return now }
+
+				report, err := c.CheckHealth(context.Background(), "DEVICE")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if report.Health != tt.want {
+					t.Errorf("unexpected health: %s != %s (reasons: %v)", report.Health, tt.want, report.Reasons)
+				}
+				if report.Stale != stale {
+					t.Errorf("unexpected stale: %t != %t", report.Stale, stale)
+				}
+			})
+		}
+	}
+}
+
+func TestCountRecentDriveFailures(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	pages := []HistoryPage{
+		{Type: DriveFailed, Timestamp: now.Add(time.Minute)}, // future
+		{Type: DriveFailed, Timestamp: now},                  // now
+		{Type: DriveFailed, Timestamp: now.Add(-30 * time.Minute)},
+		{Type: DriveLocked, Timestamp: now.Add(-40 * time.Minute)}, // other type
+		{Type: DriveFailed, Timestamp: now.Add(-time.Hour)},        // boundary
+		{Type: DriveFailed, Timestamp: now.Add(-2 * time.Hour)},    // too old
+	}
+	if got := CountRecentDriveFailures(pages, time.Hour, now); got != 3 {
+		t.Errorf("unexpected count: %d != 3", got)
+	}
+	if got := CountRecentDriveFailures(pages, time.Minute, now); got != 1 {
+		t.Errorf("unexpected count: %d != 1", got)
+	}
+}
+
+func TestTooManyDriveFailures(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	var records []HistoryPage
+	for i := range 6 {
+		// DriveFailed every 20 minutes, newest first
+		records = append(records, HistoryPage{RecordID: 6 - i, Type: DriveFailed, Timestamp: now.Add(-time.Duration(i) * 20 * time.Minute)})
+	}
+
+	tests := []struct {
+		threshold int
+		want      bool
+	}{
+		{threshold: 3, want: true},
+		{threshold: 4, want: false}, // 0m, 20m, 40m and 60m ago are within an hour
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("threshold=%d", tt.threshold), func(t *testing.T) {
+			var requests []string
+			c := newTestClient(t, newHistoryRecordsServer(t, records, &requests))
+			c.Now = func() time.Time { return now }
+			c.DefaultPageSize = 2
+
+			got, err := c.TooManyDriveFailures(context.Background(), "DEVICE", time.Hour, tt.threshold)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("unexpected result: %t != %t", got, tt.want)
+			}
+			// the third page starts with the record 80 minutes ago, so no more pages should be fetched
+			if len(requests) != 3 {
+				t.Errorf("history should not be fetched beyond the window: %v", requests)
+			}
+		})
+	}
+}
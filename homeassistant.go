@@ -0,0 +1,40 @@
+package main
+
+import "fmt"
+
+// HomeAssistantState is the payload for Home Assistant lock entities.
+type HomeAssistantState struct {
+	State   string `json:"state"`
+	Battery int    `json:"battery"`
+}
+
+// Home Assistant lock states
+const (
+	HomeAssistantLocked   = "locked"
+	HomeAssistantUnlocked = "unlocked"
+)
+
+// HomeAssistantState converts the status into the payload for Home Assistant.
+// Moved is reported as unlocked since the lock is not in the lock range.
+func (s *StatusResponse) HomeAssistantState() HomeAssistantState {
+	state := HomeAssistantUnlocked
+	if s.Status == Locked {
+		state = HomeAssistantLocked
+	}
+	return HomeAssistantState{
+		State:   state,
+		Battery: s.BatteryPercentage,
+	}
+}
+
+// ParseHomeAssistantCommand converts the command from Home Assistant ("LOCK" or "UNLOCK")
+// into the state which the device should be.
+func ParseHomeAssistantCommand(cmd string) (State, error) {
+	switch cmd {
+	case "LOCK":
+		return Locked, nil
+	case "UNLOCK":
+		return Unlocked, nil
+	}
+	return "", fmt.Errorf("unknown Home Assistant command: %s", cmd)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestHomeAssistantState(t *testing.T) {
+	tests := []struct {
+		state State
+		want  string
+	}{
+		{Locked, `{"state":"locked","battery":80}`},
+		{Unlocked, `{"state":"unlocked","battery":80}`},
+		{Moved, `{"state":"unlocked","battery":80}`},
+	}
+	for _, tt := range tests {
+		status := StatusResponse{Status: tt.state, BatteryPercentage: 80}
+		b, err := json.Marshal(status.HomeAssistantState())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("unexpected payload for %s: %s != %s", tt.state, b, tt.want)
+		}
+	}
+}
+
+func TestParseHomeAssistantCommand(t *testing.T) {
+	tests := []struct {
+		cmd     string
+		want    State
+		wantErr bool
+	}{
+		{cmd: "LOCK", want: Locked},
+		{cmd: "UNLOCK", want: Unlocked},
+		{cmd: "lock", wantErr: true},
+		{cmd: "OPEN", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseHomeAssistantCommand(tt.cmd)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("unexpected error for %s: %v", tt.cmd, err)
+		}
+		if got != tt.want {
+			t.Errorf("unexpected state for %s: %q != %q", tt.cmd, got, tt.want)
+		}
+	}
+}
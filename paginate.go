@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"slices"
+)
+
+// paginate returns an iterator over the items fetched page by page, starting from page 0.
+// The iteration stops after the page fetch reports as the last one, or yields the error
+// and stops when fetch fails or ctx is done.
+func paginate[T any](ctx context.Context, fetch func(page int) (items []T, last bool, err error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		for page := 0; ; page++ {
+			if err := ctx.Err(); err != nil {
+				yield(zero, err)
+				return
+			}
+
+			items, last, err := fetch(page)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if last {
+				return
+			}
+		}
+	}
+}
+
+// HistorySeq returns an iterator over all history records of the device, newest first.
+// Pages are fetched transparently with pageSize records each.
+// The iteration stops after yielding an error, including the one of ctx.
+// Invalid uuid or pageSize is yielded as an error before any request.
+// When the server rejects pageSize, a smaller page size is negotiated on the first page as HistoryNegotiated does.
+//
+//	for page, err := range client.HistorySeq(ctx, uuid, 50) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func (c *Client) HistorySeq(ctx context.Context, uuid string, pageSize int) iter.Seq2[HistoryPage, error] {
+	err := c.validate(ctx, uuid)
+	if err == nil {
+		err = validatePaging(0, pageSize)
+	}
+	if err != nil {
+		return func(yield func(HistoryPage, error) bool) {
+			yield(HistoryPage{}, fmt.Errorf("sesame: HistorySeq: %w", err))
+		}
+	}
+
+	var accepted int
+	return paginate(ctx, func(page int) ([]HistoryPage, bool, error) {
+		var (
+			hist *HistoryResponse
+			err  error
+		)
+		if page == 0 {
+			hist, accepted, err = c.HistoryNegotiated(ctx, uuid, page, pageSize)
+		} else {
+			hist, err = c.History(ctx, uuid, page, accepted)
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		return hist.Pages, len(hist.Pages) < accepted, nil
+	})
+}
+
+// StreamHistoryFiltered pages through all history records of the device
+// and calls fn only for the records whose type is one of types.
+// This stops when fn returns an error or ctx is done, returning the error.
+// The error returned by fn is returned as is.
+func (c *Client) StreamHistoryFiltered(ctx context.Context, uuid string, types []HistoryType, fn func(HistoryPage) error) error {
+	if err := c.validate(ctx, uuid); err != nil {
+		return fmt.Errorf("sesame: StreamHistoryFiltered: %w", err)
+	}
+	if fn == nil {
+		return fmt.Errorf("sesame: StreamHistoryFiltered: %w", &ValidationError{Field: "fn", Reason: "must not be nil"})
+	}
+
+	for page, err := range c.HistorySeq(ctx, uuid, c.pageSize()) {
+		if err != nil {
+			return fmt.Errorf("sesame: StreamHistoryFiltered: %w", err)
+		}
+		if !slices.Contains(types, page.Type) {
+			continue
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// newHistoryServer returns a handler serving records newest first,
+// which rejects the page size larger than maxPageSize with 400 Bad Request.
+func newHistoryServer(t *testing.T, records, maxPageSize int, requests *[]string) http.Handler {
+	t.Helper()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.URL.RawQuery)
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("lg"))
+		if size > maxPageSize {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"Pages": [`)
+		for i := 0; i < size; i++ {
+			id := records - page*size - i
+			if id <= 0 {
+				break
+			}
+			if i > 0 {
+				fmt.Fprint(w, ",")
+			}
+			fmt.Fprintf(w, `{"recordID": %d}`, id)
+		}
+		fmt.Fprint(w, `]}`)
+	})
+}
+
+// newHistoryRecordsServer returns a handler serving the given records, newest first, page by page.
+func newHistoryRecordsServer(t *testing.T, records []HistoryPage, requests *[]string) http.Handler {
+	t.Helper()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.URL.RawQuery)
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("lg"))
+		start := min(page*size, len(records))
+		end := min(start+size, len(records))
+		if err := json.NewEncoder(w).Encode(map[string][]HistoryPage{"Pages": records[start:end]}); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestHistorySeq(t *testing.T) {
+	var requests []string
+	c := newTestClient(t, newHistoryServer(t, 5, 2, &requests))
+
+	var ids []int
+	for page, err := range c.HistorySeq(context.Background(), "DEVICE", 8) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, page.RecordID)
+	}
+
+	if want := []int{5, 4, 3, 2, 1}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("unexpected records: %v != %v", ids, want)
+	}
+	want := []string{"lg=8&page=0", "lg=4&page=0", "lg=2&page=0", "lg=2&page=1", "lg=2&page=2"}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("unexpected requests:\n  got:  %v\n  want: %v", requests, want)
+	}
+}
+
+func TestHistoryNegotiatedGivesUp(t *testing.T) {
+	var requests []string
+	c := newTestClient(t, newHistoryServer(t, 5, 0, &requests))
+
+	if _, _, err := c.HistoryNegotiated(context.Background(), "DEVICE", 0, 64); err == nil {
+		t.Error("error is expected but got nil")
+	}
+	if want := []string{"lg=64&page=0", "lg=32&page=0", "lg=16&page=0", "lg=8&page=0"}; !reflect.DeepEqual(requests, want) {
+		t.Errorf("unexpected requests:\n  got:  %v\n  want: %v", requests, want)
+	}
+}
+
+func TestHistoryNegotiatesOnlyFirstPage(t *testing.T) {
+	// the server rejects any page reaching beyond 40 records
+	var requests []string
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("lg"))
+		if (page+1)*size > 40 {
+			requests = append(requests, r.URL.RawQuery)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		newHistoryServer(t, 100, 100, &requests).ServeHTTP(w, r)
+	}))
+	c.DefaultPageSize = 64
+
+	tests := []struct {
+		name string
+		fn   func() error
+	}{
+		{"HistoryOldestFirst", func() error { _, err := c.HistoryOldestFirst(context.Background(), "DEVICE", 64); return err }},
+		{"HistoryByRecordRange", func() error { _, err := c.HistoryByRecordRange(context.Background(), "DEVICE", 1, 100); return err }},
+		{"SyncHistory", func() error { _, err := c.SyncHistory(context.Background(), "DEVICE", memoryCursorStore{}); return err }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = nil
+			var httpErr *HTTPError
+			if err := tt.fn(); !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusBadRequest {
+				t.Errorf("400 Bad Request is expected but got: %v", err)
+			}
+			// the page size is negotiated only on the first page, and a later 400 is returned as is
+			if want := []string{"lg=64&page=0", "lg=32&page=0", "lg=32&page=1"}; !reflect.DeepEqual(requests, want) {
+				t.Errorf("unexpected requests:\n  got:  %v\n  want: %v", requests, want)
+			}
+		})
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	// pages of 3 items: [0 1 2] [3 4 5] [6]
+	var fetched []int
+	fetch := func(page int) ([]int, bool, error) {
+		fetched = append(fetched, page)
+		var items []int
+		for i := page * 3; i < min((page+1)*3, 7); i++ {
+			items = append(items, i)
+		}
+		return items, len(items) < 3, nil
+	}
+
+	t.Run("all", func(t *testing.T) {
+		fetched = nil
+		var got []int
+		for item, err := range paginate(context.Background(), fetch) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, item)
+		}
+		if want := []int{0, 1, 2, 3, 4, 5, 6}; !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected items: %v != %v", got, want)
+		}
+		if want := []int{0, 1, 2}; !reflect.DeepEqual(fetched, want) {
+			t.Errorf("unexpected fetched pages: %v != %v", fetched, want)
+		}
+	})
+
+	t.Run("break", func(t *testing.T) {
+		fetched = nil
+		var got []int
+		for item, err := range paginate(context.Background(), fetch) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			if item == 4 {
+				break
+			}
+			got = append(got, item)
+		}
+		if want := []int{0, 1, 2, 3}; !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected items: %v != %v", got, want)
+		}
+		if want := []int{0, 1}; !reflect.DeepEqual(fetched, want) {
+			t.Errorf("no more page should be fetched after break: %v", fetched)
+		}
+	})
+
+	t.Run("fetch error", func(t *testing.T) {
+		fetchErr := errors.New("fetch error")
+		var items, errs int
+		for _, err := range paginate(context.Background(), func(page int) ([]int, bool, error) {
+			if page == 1 {
+				return nil, false, fetchErr
+			}
+			return fetch(page)
+		}) {
+			if err != nil {
+				if !errors.Is(err, fetchErr) {
+					t.Errorf("unexpected error: %v", err)
+				}
+				errs++
+				continue
+			}
+			items++
+		}
+		if items != 3 || errs != 1 {
+			t.Errorf("iteration should stop after the error: %d items, %d errors", items, errs)
+		}
+	})
+
+	t.Run("ctx canceled", func(t *testing.T) {
+		fetched = nil
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var items int
+		var gotErr error
+		for _, err := range paginate(ctx, fetch) {
+			if err != nil {
+				gotErr = err
+				continue
+			}
+			items++
+			if items == 3 {
+				cancel()
+			}
+		}
+		if !errors.Is(gotErr, context.Canceled) {
+			t.Errorf("context.Canceled is expected but got: %v", gotErr)
+		}
+		if items != 3 || !reflect.DeepEqual(fetched, []int{0}) {
+			t.Errorf("no more page should be fetched after cancel: %d items, fetched %v", items, fetched)
+		}
+	})
+}
+
+func TestStreamHistoryFiltered(t *testing.T) {
+	var records []HistoryPage
+	for i := range 7 {
+		typ := DriveLocked
+		if i%2 == 0 {
+			typ = DriveUnlocked
+		}
+		records = append(records, HistoryPage{RecordID: 7 - i, Type: typ})
+	}
+
+	t.Run("all", func(t *testing.T) {
+		var requests []string
+		c := newTestClient(t, newHistoryRecordsServer(t, records, &requests))
+		c.DefaultPageSize = 2
+
+		var ids []int
+		err := c.StreamHistoryFiltered(context.Background(), "DEVICE", []HistoryType{DriveLocked}, func(page HistoryPage) error {
+			if page.Type != DriveLocked {
+				t.Errorf("unexpected record type is passed: %s", page.Type)
+			}
+			ids = append(ids, page.RecordID)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []int{6, 4, 2}; !reflect.DeepEqual(ids, want) {
+			t.Errorf("unexpected record IDs: %v != %v", ids, want)
+		}
+		if len(requests) != 4 {
+			t.Errorf("all pages should be fetched: %v", requests)
+		}
+	})
+
+	t.Run("fn error", func(t *testing.T) {
+		var requests []string
+		c := newTestClient(t, newHistoryRecordsServer(t, records, &requests))
+		c.DefaultPageSize = 2
+
+		fnErr := errors.New("fn error")
+		var calls int
+		err := c.StreamHistoryFiltered(context.Background(), "DEVICE", []HistoryType{DriveLocked}, func(page HistoryPage) error {
+			calls++
+			if page.RecordID == 4 {
+				return fnErr
+			}
+			return nil
+		})
+		if err != fnErr {
+			t.Errorf("the error from fn should be returned as is: %v", err)
+		}
+		if calls != 2 {
+			t.Errorf("fn should not be called after the error: %d calls", calls)
+		}
+		if len(requests) != 2 {
+			t.Errorf("no more page should be fetched after the error: %v", requests)
+		}
+	})
+}
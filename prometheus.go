@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// prometheusFamilies are the metric families written by WritePrometheusMetrics, in order.
+var prometheusFamilies = []struct {
+	name  string
+	value func(s *StatusResponse) string
+}{
+	{"sesame_battery_percent", func(s *StatusResponse) string { return strconv.Itoa(s.BatteryPercentage) }},
+	{"sesame_battery_voltage", func(s *StatusResponse) string { return strconv.FormatFloat(s.BatteryVoltage, 'g', -1, 64) }},
+	{"sesame_position", func(s *StatusResponse) string { return strconv.Itoa(s.Position) }},
+	{"sesame_locked", func(s *StatusResponse) string {
+		if s.Status == Locked {
+			return "1"
+		}
+		return "0"
+	}},
+}
+
+// WritePrometheus writes the status in Prometheus text exposition format, labeled with uuid.
+// This allows exposing metrics without any Prometheus client library.
+// The output contains TYPE lines, so use WritePrometheusMetrics to expose multiple devices in one response.
+func (s *StatusResponse) WritePrometheus(w io.Writer, uuid string) error {
+	return WritePrometheusMetrics(w, map[string]*StatusResponse{uuid: s})
+}
+
+// WritePrometheusMetrics writes the statuses keyed by UUID in Prometheus text exposition format.
+// Each metric family is written once with the samples of all devices ordered by UUID,
+// since the format doesn't allow a family to appear more than once.
+func WritePrometheusMetrics(w io.Writer, statuses map[string]*StatusResponse) error {
+	uuids := make([]string, 0, len(statuses))
+	for uuid := range statuses {
+		uuids = append(uuids, uuid)
+	}
+	slices.Sort(uuids)
+
+	var b strings.Builder
+	for _, family := range prometheusFamilies {
+		fmt.Fprintf(&b, "# TYPE %s gauge\n", family.name)
+		for _, uuid := range uuids {
+			fmt.Fprintf(&b, "%s{uuid=\"%s\"} %s\n", family.name, prometheusLabelEscaper.Replace(uuid), family.value(statuses[uuid]))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWritePrometheusMetrics(t *testing.T) {
+	statuses := map[string]*StatusResponse{
+		"B":   {BatteryPercentage: 50, BatteryVoltage: 5.5, Position: -10, Status: Unlocked},
+		`A"\`: {BatteryPercentage: 100, BatteryVoltage: 5.85, Position: 11, Status: Locked},
+	}
+
+	var b strings.Builder
+	if err := WritePrometheusMetrics(&b, statuses); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# TYPE sesame_battery_percent gauge
+sesame_battery_percent{uuid="A\"\\"} 100
+sesame_battery_percent{uuid="B"} 50
+# TYPE sesame_battery_voltage gauge
+sesame_battery_voltage{uuid="A\"\\"} 5.85
+sesame_battery_voltage{uuid="B"} 5.5
+# TYPE sesame_position gauge
+sesame_position{uuid="A\"\\"} 11
+sesame_position{uuid="B"} -10
+# TYPE sesame_locked gauge
+sesame_locked{uuid="A\"\\"} 1
+sesame_locked{uuid="B"} 0
+`
+	if got := b.String(); got != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	var b strings.Builder
+	status := &StatusResponse{BatteryPercentage: 100, BatteryVoltage: 5.85, Position: 11, Status: Locked}
+	if err := status.WritePrometheus(&b, "A"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# TYPE sesame_battery_percent gauge
+sesame_battery_percent{uuid="A"} 100
+# TYPE sesame_battery_voltage gauge
+sesame_battery_voltage{uuid="A"} 5.85
+# TYPE sesame_position gauge
+sesame_position{uuid="A"} 11
+# TYPE sesame_locked gauge
+sesame_locked{uuid="A"} 1
+`
+	if got := b.String(); got != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateLimitInfo is the rate limit information reported by the server.
+type RateLimitInfo struct {
+	// Maximum number of requests in the current window. Zero when not reported.
+	Limit int
+	// Number of requests remaining in the current window.
+	Remaining int
+	// Time when the current window resets. Zero value when not reported.
+	Reset time.Time
+}
+
+// parseRateLimit parses X-RateLimit-* headers.
+// false will be returned when X-RateLimit-Remaining header is not present or malformed.
+func parseRateLimit(header http.Header) (RateLimitInfo, bool) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return RateLimitInfo{}, false
+	}
+
+	info := RateLimitInfo{Remaining: remaining}
+	if limit, err := strconv.Atoi(header.Get("X-RateLimit-Limit")); err == nil {
+		info.Limit = limit
+	}
+	// the reset time is given as unix time in seconds
+	if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		info.Reset = time.Unix(reset, 0)
+	}
+	return info, true
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   RateLimitInfo
+		wantOK bool
+	}{
+		{
+			name:   "all",
+			header: map[string]string{"X-RateLimit-Limit": "100", "X-RateLimit-Remaining": "42", "X-RateLimit-Reset": "1600000000"},
+			want:   RateLimitInfo{Limit: 100, Remaining: 42, Reset: time.Unix(1600000000, 0)},
+			wantOK: true,
+		},
+		{
+			name:   "remaining only",
+			header: map[string]string{"X-RateLimit-Remaining": "0"},
+			want:   RateLimitInfo{Remaining: 0},
+			wantOK: true,
+		},
+		{
+			name:   "without remaining",
+			header: map[string]string{"X-RateLimit-Limit": "100"},
+		},
+		{
+			name:   "malformed remaining",
+			header: map[string]string{"X-RateLimit-Remaining": "many"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for k, v := range tt.header {
+				header.Set(k, v)
+			}
+			got, ok := parseRateLimit(header)
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unexpected result: (%+v, %t) != (%+v, %t)", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestOnRateLimit(t *testing.T) {
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/LIMITED" {
+			w.Header().Set("X-RateLimit-Limit", "100")
+			w.Header().Set("X-RateLimit-Remaining", "1")
+		}
+		_, _ = io.WriteString(w, `{}`)
+	}))
+	var infos []RateLimitInfo
+	c.OnRateLimit = func(info RateLimitInfo) { infos = append(infos, info) }
+
+	for _, uuid := range []string{"LIMITED", "UNLIMITED"} {
+		if _, err := c.Status(context.Background(), uuid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []RateLimitInfo{{Limit: 100, Remaining: 1}}; !reflect.DeepEqual(infos, want) {
+		t.Errorf("OnRateLimit should be called only with rate limit headers: %+v", infos)
+	}
+}
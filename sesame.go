@@ -1,23 +1,160 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
 const DefaultEndpoint = "https://app.candyhouse.co/api/sesame2"
 
+const DefaultAPIKeyHeader = "x-api-key"
+
+const DefaultMinPollInterval = time.Second
+
+const DefaultMaxResponseHeaderBytes = 64 << 10
+
+const DefaultMinCommandBattery = 10
+
+// ErrInvalidSecretKey is returned when the secret key is not a hex encoded 16 bytes key.
+var ErrInvalidSecretKey = errors.New("invalid secret key")
+
+// ErrBatteryTooLow is returned when the command is refused since the battery is too low.
+var ErrBatteryTooLow = errors.New("battery too low")
+
+// ErrUnauthorized is returned when the API key is rejected by the server.
+var ErrUnauthorized = errors.New("unauthorized")
+
+// HTTPError is returned when the server responses unexpected HTTP status.
+type HTTPError struct {
+	StatusCode int
+	Status     string
+}
+
+func newHTTPError(resp *http.Response) *HTTPError {
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+	}
+}
+
+func (e *HTTPError) Error() string {
+	return "unexpected HTTP status: " + e.Status
+}
+
+// ValidationError is returned when the arguments or the client configuration is invalid.
+// Any network call is not made when this error is returned.
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
 type Client struct {
 	// API endpoint. DefaultEndpoint will be used when this value is empty.
 	Endpoint string
 	// API key you can get via https://dash.candyhouse.co
 	APIKey string
+	// Header name carrying the API key. DefaultAPIKeyHeader will be used when this value is empty.
+	// This is useful when the client is behind a reverse proxy which expects a different header.
+	APIKeyHeader string
+	// Accept-Language header sent with every request, e.g. "en".
+	// The header is not sent when this value is empty.
+	AcceptLanguage string
+	// If true, UUIDs are upper-cased in the request path since the server requires upper-case UUIDs.
+	// This only affects the request path, UUIDs given by the caller are kept as is elsewhere.
+	AutoUppercaseUUID bool
+	// If true, unknown fields in the response body cause decoding errors.
+	// This is useful to catch API changes during testing.
+	StrictDecoding bool
+	// Function decoding the response body into v. encoding/json will be used when this value is nil.
+	// StrictDecoding is ignored when this value is set.
+	Decoder func(r io.Reader, v interface{}) error
+	// Alternate field names of the status payload keyed by the alias, mapped to the standard field name,
+	// e.g. {"battery": "batteryPercentage"}. These are used in addition to the known aliases
+	// so that the client works with firmware versions reporting different field names.
+	StatusFieldAliases map[string]string
+	// If true, command APIs build and sign the request but don't send it.
+	// This is useful to test automation logic without moving the device.
+	// No API call is made in dry-run mode, so CommandPreconditions are not checked either.
+	DryRun bool
+	// If true, command APIs read the status first and refuse to send the command
+	// when the battery percentage is lower than MinCommandBattery, to avoid a jam.
+	// Note that this costs an extra API call for each command.
+	CommandPreconditions bool
+	// Minimum battery percentage required to send commands when CommandPreconditions is true.
+	// DefaultMinCommandBattery will be used when this value is zero.
+	MinCommandBattery int
+	// Function returning the current time, used for signing commands and so on.
+	// time.Now will be used when this value is nil.
+	Now func() time.Time
+	// Function returning a channel which receives after the duration, used for waiting between polls.
+	// time.After will be used when this value is nil.
+	After func(d time.Duration) <-chan time.Time
+	// Function called with the rate limit information when the response has rate limit headers.
+	// This is useful to slow down before the server starts to reject requests.
+	OnRateLimit func(RateLimitInfo)
+	// Function called after each HTTP request attempt with the operation name (e.g. "Status"),
+	// the attempt number, the HTTP status code (zero on error), the error and the elapsed time.
+	// The client doesn't retry requests for now, so the attempt number is always 1.
+	OnAttempt func(op string, attempt int, status int, err error, elapsed time.Duration)
+	// Nicknames of devices keyed by UUID, which are shown instead of UUIDs by Nickname.
+	// The API doesn't provide device list, so this should be configured by the user.
+	Nicknames map[string]string
+	// Page size used when paging through history without explicit maxResults,
+	// e.g. HistoryDefault and HistoryByRecordRange. 50 will be used when this value is zero.
+	DefaultPageSize int
+	// Minimum poll interval of polling helpers like WaitForBattery. Smaller intervals are clamped to this value
+	// to avoid hammering the API. DefaultMinPollInterval will be used when this value is zero.
+	MinPollInterval time.Duration
+	// If positive, Status returns the cached status within this duration since it's fetched,
+	// and concurrent calls for the same device share one request.
+	StatusCacheTTL time.Duration
+	// Decoders of the response body keyed by content coding, e.g. "zstd" or "br",
+	// which are offered to the server via Accept-Encoding in addition to gzip.
+	// When this value is empty, only gzip is used transparently by net/http.
+	Decompressors map[string]Decompressor
+
+	// HTTP client used for API requests.
+	// When this value is nil, the client builds its own one configured with the transport settings below.
+	HTTPClient *http.Client
+	// Maximum number of idle connections kept by the transport. Zero means the default of net/http.
+	// Ignored when HTTPClient is set.
+	MaxIdleConns int
+	// How long an idle connection is kept by the transport. Zero means the default of net/http.
+	// Ignored when HTTPClient is set.
+	IdleConnTimeout time.Duration
+	// Maximum number of connections per host, including active ones. Zero means no limit.
+	// Ignored when HTTPClient is set.
+	MaxConnsPerHost int
+	// Maximum size of response headers in bytes. DefaultMaxResponseHeaderBytes will be used when this value is zero.
+	// Ignored when HTTPClient is set.
+	MaxResponseHeaderBytes int64
+	// Proxy used for API requests. HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+	// will be honored when this value is nil. Ignored when HTTPClient is set.
+	Proxy *url.URL
+
+	once       sync.Once
+	httpClient *http.Client
+
+	cacheMu     sync.Mutex
+	statusCache map[string]*statusCacheEntry
 }
 
 type StatusResponse struct {
@@ -26,6 +163,44 @@ type StatusResponse struct {
 	Position          int       `json:"position"`
 	Status            State     `json:"CHSesame2Status"`
 	Timestamp         time.Time `json:"timestamp"`
+	// Raw mechanical status. nil when the value is not reported.
+	MechStatus *MechStatus `json:"mechStatus"`
+	// Whether WiFi Module 2 is connected. nil when the value is not reported.
+	WiFiModuleState *bool `json:"wm2State"`
+	// Auto lock delay in seconds, zero when auto lock is disabled. nil when the value is not reported.
+	AutoLockSecond *int `json:"autoLockSecond"`
+
+	// Raw response body, which contains the fields not modeled by this struct.
+	Raw json.RawMessage `json:"-"`
+}
+
+// MechSettings is the position ranges regarded as locked and unlocked.
+// The API doesn't provide the device settings, so this should be configured by the user.
+type MechSettings struct {
+	LockRangeMin   int
+	LockRangeMax   int
+	UnlockRangeMin int
+	UnlockRangeMax int
+}
+
+// DerivedState computes the state from the raw position and settings, independent of Status.
+// This is useful when Status reported by the server lags behind the actual position.
+// Moved will be returned when the position is in neither lock nor unlock range.
+func (s *StatusResponse) DerivedState(settings MechSettings) State {
+	switch {
+	case settings.LockRangeMin <= s.Position && s.Position <= settings.LockRangeMax:
+		return Locked
+	case settings.UnlockRangeMin <= s.Position && s.Position <= settings.UnlockRangeMax:
+		return Unlocked
+	}
+	return Moved
+}
+
+// MechStatus is the raw mechanical status reported by the device.
+// These are more reliable than Status during calibration.
+type MechStatus struct {
+	InLockRange   bool `json:"isInLockRange"`
+	InUnlockRange bool `json:"isInUnlockRange"`
 }
 
 type State string
@@ -38,25 +213,176 @@ const (
 
 func (state State) String() string { return string(state) }
 
+// IsKnown reports whether the state is one of Locked, Unlocked or Moved.
+// State keeps the raw value reported by the device even when it's unknown.
+func (state State) IsKnown() bool {
+	switch state {
+	case Locked, Unlocked, Moved:
+		return true
+	}
+	return false
+}
+
+// MaxPosition is the maximum value of the raw position.
+const MaxPosition = 1023
+
+// Warnings reports the values of the status which are out of the possible range.
+// The server sometimes responses nonsensical values during device boot.
+// nil will be returned when the status seems valid.
+func (s *StatusResponse) Warnings() []string {
+	var warnings []string
+	if s.BatteryPercentage < 0 || 100 < s.BatteryPercentage {
+		warnings = append(warnings, fmt.Sprintf("battery percentage is out of range: %d", s.BatteryPercentage))
+	}
+	if s.BatteryVoltage < 0 {
+		warnings = append(warnings, fmt.Sprintf("battery voltage is negative: %f", s.BatteryVoltage))
+	}
+	if s.Position < 0 || MaxPosition < s.Position {
+		warnings = append(warnings, fmt.Sprintf("position is out of range: %d", s.Position))
+	}
+	return warnings
+}
+
+// Fields returns the status as a flat map, which is convenient to push to metrics systems.
+func (s *StatusResponse) Fields() map[string]interface{} {
+	return map[string]interface{}{
+		"status":          s.Status.String(),
+		"battery_percent": s.BatteryPercentage,
+		"battery_voltage": s.BatteryVoltage,
+		"position":        s.Position,
+		"timestamp":       s.Timestamp,
+	}
+}
+
+// LogLine returns the status in a single line for logs, like:
+//
+//	state=locked battery=87% voltage=5.90 pos=512 ts=2021-06-01T12:34:56Z
+//
+// The keys are kept stable for grepping.
+func (s *StatusResponse) LogLine() string {
+	return fmt.Sprintf("state=%s battery=%d%% voltage=%.2f pos=%d ts=%s",
+		s.Status, s.BatteryPercentage, s.BatteryVoltage, s.Position, s.Timestamp.Format(time.RFC3339))
+}
+
+// AutoLockEnabled reports whether auto lock is enabled.
+// known is false when the device doesn't report the auto lock setting.
+func (s *StatusResponse) AutoLockEnabled() (enabled, known bool) {
+	if s.AutoLockSecond == nil {
+		return false, false
+	}
+	return *s.AutoLockSecond > 0, true
+}
+
+// AutoLockDelay returns the delay until the device locks automatically.
+// Zero will be returned when auto lock is disabled or not reported.
+func (s *StatusResponse) AutoLockDelay() time.Duration {
+	if s.AutoLockSecond == nil {
+		return 0
+	}
+	return time.Duration(*s.AutoLockSecond) * time.Second
+}
+
+// BinaryLocked reports whether the device is locked, treating Moved as unlocked.
+// This is for simple consumers which want a boolean. Use BinaryLockedOr to resolve Moved differently.
+func (s *StatusResponse) BinaryLocked() bool {
+	return s.BinaryLockedOr(false)
+}
+
+// BinaryLockedOr reports whether the device is locked, returning moved when the state is Moved.
+func (s *StatusResponse) BinaryLockedOr(moved bool) bool {
+	switch s.Status {
+	case Locked:
+		return true
+	case Moved:
+		return moved
+	}
+	return false
+}
+
+// BatteryDelta returns the change of the battery percentage from earlier to later.
+// A negative value means the battery dropped. When later is actually older than earlier,
+// they are swapped so that the result always represents the change over time.
+func BatteryDelta(earlier, later *StatusResponse) int {
+	if later.Timestamp.Before(earlier.Timestamp) {
+		earlier, later = later, earlier
+	}
+	return later.BatteryPercentage - earlier.BatteryPercentage
+}
+
+// IsProbablyOffline reports whether the status is older than maxAge at now.
+// A device which lost its connection keeps being reported with its last-known status and old timestamp.
+func (s *StatusResponse) IsProbablyOffline(maxAge time.Duration, now time.Time) bool {
+	return now.Sub(s.Timestamp) > maxAge
+}
+
+// PositionFraction returns the position normalized into 0.0 (fully unlocked) to 1.0 (fully locked).
+// Out-of-range positions are clamped.
+func (s *StatusResponse) PositionFraction() float64 {
+	switch {
+	case s.Position <= 0:
+		return 0
+	case s.Position >= MaxPosition:
+		return 1
+	}
+	return float64(s.Position) / MaxPosition
+}
+
+// rough discharge curve of CR123A batteries, ordered by voltage descending.
+var batteryLifeCurve = []struct {
+	voltage float64
+	days    float64
+}{
+	{6.00, 300},
+	{5.85, 240},
+	{5.70, 120},
+	{5.55, 45},
+	{5.40, 14},
+	{5.20, 0},
+}
+
+// EstimatedBatteryDays returns a rough estimate of the remaining battery life in days.
+// This is just a heuristic which linearly interpolates a typical discharge curve of CR123A batteries,
+// and the result tends to be conservative. Actual battery life depends on how often the lock moves.
+func (s *StatusResponse) EstimatedBatteryDays() int {
+	v := s.BatteryVoltage
+	if v >= batteryLifeCurve[0].voltage {
+		return int(batteryLifeCurve[0].days)
+	}
+	for i := 1; i < len(batteryLifeCurve); i++ {
+		hi, lo := batteryLifeCurve[i-1], batteryLifeCurve[i]
+		if v >= lo.voltage {
+			return int(lo.days + (hi.days-lo.days)*(v-lo.voltage)/(hi.voltage-lo.voltage))
+		}
+	}
+	return 0
+}
+
 // Status API
 // https://doc.candyhouse.co/ja/SesameAPI#sesame%E3%81%AE%E7%8A%B6%E6%85%8B%E3%82%92%E5%8F%96%E5%BE%97
 // The server responses internal server error when uuid is not found or invalid. UUID string must be upper case.
+// When StatusCacheTTL is set, the cached status may be returned.
 func (c *Client) Status(ctx context.Context, uuid string) (*StatusResponse, error) {
-	endpoint := c.Endpoint
-	if endpoint == "" {
-		endpoint = DefaultEndpoint
+	if c.StatusCacheTTL > 0 {
+		return c.cachedStatus(ctx, uuid)
+	}
+	return c.status(ctx, uuid)
+}
+
+func (c *Client) status(ctx context.Context, uuid string) (*StatusResponse, error) {
+	if err := c.validate(ctx, uuid); err != nil {
+		return nil, fmt.Errorf("sesame: Status: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/"+uuid, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.deviceURL(uuid), nil)
 	if err != nil {
-		return nil, fmt.Errorf("creating new HTTP request: %w", err)
+		return nil, fmt.Errorf("sesame: Status: creating new HTTP request: %w", err)
 	}
 
-	req.Header.Add("x-api-key", c.APIKey)
+	req.Header.Add(c.apiKeyHeader(), c.apiKey(ctx))
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do("Status", req)
 	if err != nil {
-		return nil, fmt.Errorf("doing HTTP request: %w", err)
+		return nil, fmt.Errorf("sesame: Status: doing HTTP request: %w", err)
 	}
 	defer func() {
 		_, _ = io.Copy(io.Discard, resp.Body)
@@ -64,17 +390,285 @@ func (c *Client) Status(ctx context.Context, uuid string) (*StatusResponse, erro
 	}()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected HTTP status: %s", resp.Status)
+		return nil, fmt.Errorf("sesame: Status: %w", newHTTPError(resp))
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("sesame: Status: reading response body: %w", err)
 	}
 
 	var status StatusResponse
-	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
-		return nil, fmt.Errorf("decoding response body: %w", err)
+	if err := c.decode(bytes.NewReader(c.resolveStatusAliases(body)), &status); err != nil {
+		return nil, fmt.Errorf("sesame: Status: decoding response body: %w", err)
 	}
+	status.Raw = body
 
 	return &status, nil
 }
 
+func (c *Client) validate(ctx context.Context, uuid string) error {
+	if err := c.validateAPIKey(ctx); err != nil {
+		return err
+	}
+	if uuid == "" {
+		return &ValidationError{Field: "uuid", Reason: "must not be empty"}
+	}
+	if uuid == "." || uuid == ".." || strings.ContainsAny(uuid, "/?#%") {
+		return &ValidationError{Field: "uuid", Reason: "must not contain path or query characters"}
+	}
+	return nil
+}
+
+func (c *Client) validateAPIKey(ctx context.Context) error {
+	if c.apiKey(ctx) == "" {
+		return &ValidationError{Field: "APIKey", Reason: "must not be empty"}
+	}
+	return nil
+}
+
+func validatePaging(page, maxResults int) error {
+	if page < 0 {
+		return &ValidationError{Field: "page", Reason: "must not be negative"}
+	}
+	if maxResults <= 0 {
+		return &ValidationError{Field: "maxResults", Reason: "must be positive"}
+	}
+	return nil
+}
+
+func validateSecretKey(secretKey string) error {
+	if secretKey == "" {
+		return &ValidationError{Field: "secretKey", Reason: "must not be empty"}
+	}
+	return nil
+}
+
+// ConfigSummary returns a human-readable summary of the client configuration for diagnostics.
+// Effective values are shown, i.e. defaults are filled in for zero values. The API key is redacted.
+func (c *Client) ConfigSummary() string {
+	apiKey := "<empty>"
+	if c.APIKey != "" {
+		apiKey = "<redacted>"
+	}
+	proxy := "<environment>"
+	if c.Proxy != nil {
+		proxy = c.Proxy.Redacted()
+	}
+	statusCacheTTL := "<disabled>"
+	if c.StatusCacheTTL > 0 {
+		statusCacheTTL = c.StatusCacheTTL.String()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Endpoint: %s\n", c.endpoint())
+	fmt.Fprintf(&b, "APIKey: %s\n", apiKey)
+	fmt.Fprintf(&b, "APIKeyHeader: %s\n", c.apiKeyHeader())
+	fmt.Fprintf(&b, "StrictDecoding: %t\n", c.StrictDecoding)
+	fmt.Fprintf(&b, "DryRun: %t\n", c.DryRun)
+	fmt.Fprintf(&b, "CommandPreconditions: %t\n", c.CommandPreconditions)
+	fmt.Fprintf(&b, "MinCommandBattery: %d\n", c.minCommandBattery())
+	fmt.Fprintf(&b, "DefaultPageSize: %d\n", c.pageSize())
+	fmt.Fprintf(&b, "MinPollInterval: %s\n", c.minPollInterval())
+	fmt.Fprintf(&b, "StatusCacheTTL: %s\n", statusCacheTTL)
+	fmt.Fprintf(&b, "AcceptEncoding: %s\n", c.acceptEncoding())
+	if c.HTTPClient != nil {
+		fmt.Fprintf(&b, "HTTPClient: custom (timeout: %s)\n", c.HTTPClient.Timeout)
+		return b.String()
+	}
+	transport := c.newTransport()
+	fmt.Fprintf(&b, "MaxIdleConns: %d\n", transport.MaxIdleConns)
+	fmt.Fprintf(&b, "IdleConnTimeout: %s\n", transport.IdleConnTimeout)
+	fmt.Fprintf(&b, "MaxConnsPerHost: %d\n", transport.MaxConnsPerHost)
+	fmt.Fprintf(&b, "MaxResponseHeaderBytes: %d\n", transport.MaxResponseHeaderBytes)
+	fmt.Fprintf(&b, "Proxy: %s\n", proxy)
+	return b.String()
+}
+
+// Nickname returns the nickname of the device configured in Nicknames.
+// The UUID itself will be returned when no nickname is configured.
+func (c *Client) Nickname(uuid string) string {
+	if nickname, ok := c.Nicknames[uuid]; ok {
+		return nickname
+	}
+	return uuid
+}
+
+func (c *Client) endpoint() string {
+	if c.Endpoint == "" {
+		return DefaultEndpoint
+	}
+	return c.Endpoint
+}
+
+// deviceURL returns the URL of the device. uuid is escaped so that it never alters the path.
+func (c *Client) deviceURL(uuid string) string {
+	if c.AutoUppercaseUUID {
+		uuid = strings.ToUpper(uuid)
+	}
+	return c.endpoint() + "/" + url.PathEscape(uuid)
+}
+
+func (c *Client) now() time.Time {
+	if c.Now == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
+
+func (c *Client) after(d time.Duration) <-chan time.Time {
+	if c.After == nil {
+		return time.After(d)
+	}
+	return c.After(d)
+}
+
+type apiKeyContextKey struct{}
+
+// WithAPIKey returns a new context carrying the API key,
+// which overrides Client.APIKey for the requests made with the context.
+// This is useful for servers sharing one client among multiple users.
+func WithAPIKey(ctx context.Context, apiKey string) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey{}, apiKey)
+}
+
+// apiKey returns the API key in ctx if exists, otherwise Client.APIKey.
+func (c *Client) apiKey(ctx context.Context) string {
+	if apiKey, ok := ctx.Value(apiKeyContextKey{}).(string); ok && apiKey != "" {
+		return apiKey
+	}
+	return c.APIKey
+}
+
+func (c *Client) apiKeyHeader() string {
+	if c.APIKeyHeader == "" {
+		return DefaultAPIKeyHeader
+	}
+	return c.APIKeyHeader
+}
+
+func (c *Client) client() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+
+	c.once.Do(func() {
+		c.httpClient = &http.Client{Transport: c.newTransport()}
+	})
+	return c.httpClient
+}
+
+// newTransport returns a transport configured with the transport settings of the client.
+func (c *Client) newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if c.MaxIdleConns > 0 {
+		transport.MaxIdleConns = c.MaxIdleConns
+		transport.MaxIdleConnsPerHost = c.MaxIdleConns
+	}
+	if c.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = c.IdleConnTimeout
+	}
+	if c.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = c.MaxConnsPerHost
+	}
+	transport.MaxResponseHeaderBytes = DefaultMaxResponseHeaderBytes
+	if c.MaxResponseHeaderBytes > 0 {
+		transport.MaxResponseHeaderBytes = c.MaxResponseHeaderBytes
+	}
+	if c.Proxy != nil {
+		transport.Proxy = http.ProxyURL(c.Proxy)
+	}
+	return transport
+}
+
+func (c *Client) do(op string, req *http.Request) (*http.Response, error) {
+	if c.AcceptLanguage != "" {
+		req.Header.Set("Accept-Language", c.AcceptLanguage)
+	}
+	if len(c.Decompressors) > 0 {
+		req.Header.Set("Accept-Encoding", c.acceptEncoding())
+	}
+
+	start := c.now()
+	resp, err := c.client().Do(req)
+	if c.OnAttempt != nil {
+		var status int
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		c.OnAttempt(op, 1, status, err, c.now().Sub(start))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(c.Decompressors) > 0 {
+		if err := c.decompress(resp); err != nil {
+			_ = resp.Body.Close()
+			return nil, err
+		}
+	}
+
+	if c.OnRateLimit != nil {
+		if info, ok := parseRateLimit(resp.Header); ok {
+			c.OnRateLimit(info)
+		}
+	}
+
+	return resp, nil
+}
+
+// known aliases of the status fields used by some firmware versions, keyed by the alias.
+var statusFieldAliases = map[string]string{
+	"battery": "batteryPercentage",
+}
+
+// resolveStatusAliases renames the aliased fields in the status payload to the standard names.
+// The standard field wins when both of them exist.
+func (c *Client) resolveStatusAliases(body []byte) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		// leave it to the decoder to report the error
+		return body
+	}
+
+	var changed bool
+	for _, aliases := range []map[string]string{statusFieldAliases, c.StatusFieldAliases} {
+		for alias, name := range aliases {
+			v, ok := fields[alias]
+			if !ok {
+				continue
+			}
+			if _, ok := fields[name]; !ok {
+				fields[name] = v
+			}
+			delete(fields, alias)
+			changed = true
+		}
+	}
+	if !changed {
+		return body
+	}
+
+	resolved, err := json.Marshal(fields)
+	if err != nil {
+		return body
+	}
+	return resolved
+}
+
+func (c *Client) decode(r io.Reader, v interface{}) error {
+	if c.Decoder != nil {
+		return c.Decoder(r, v)
+	}
+
+	dec := json.NewDecoder(r)
+	if c.StrictDecoding {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(v)
+}
+
 type HistoryResponse struct {
 	Pages []HistoryPage
 }
@@ -87,7 +681,16 @@ type HistoryPage struct {
 	Timestamp  time.Time   `json:"timestamp"`
 }
 
-//go:generate stringer -type=HistoryType
+// Actor returns the decoded history tag, which tells who operated the device.
+// The raw tag will be returned when it's not base64 encoded.
+func (p HistoryPage) Actor() string {
+	tag, err := base64.StdEncoding.DecodeString(p.HistoryTag)
+	if err != nil {
+		return p.HistoryTag
+	}
+	return string(tag)
+}
+
 type HistoryType int
 
 // defined at https://doc.candyhouse.co/ja/SesameAPI#sesame%E3%81%AE%E5%B1%A5%E6%AD%B4%E3%82%92%E5%8F%96%E5%BE%97
@@ -108,29 +711,71 @@ const (
 	BLEAdvParameterUpdated HistoryType = 13
 )
 
+var historyTypeNames = [...]string{
+	None:                   "None",
+	BLELock:                "BLELock",
+	BLEUnlock:              "BLEUnlock",
+	TimeChanged:            "TimeChanged",
+	AutoLockUpdated:        "AutoLockUpdated",
+	MechSettingUpdated:     "MechSettingUpdated",
+	AutoLock:               "AutoLock",
+	ManualLocked:           "ManualLocked",
+	ManualUnlocked:         "ManualUnlocked",
+	ManualElse:             "ManualElse",
+	DriveLocked:            "DriveLocked",
+	DriveUnlocked:          "DriveUnlocked",
+	DriveFailed:            "DriveFailed",
+	BLEAdvParameterUpdated: "BLEAdvParameterUpdated",
+}
+
+// An "invalid array index" compiler error signifies that historyTypeNames doesn't end with
+// the last HistoryType constant. Update both of them when a new history type is added.
+func _() {
+	var x [1]struct{}
+	_ = x[len(historyTypeNames)-1-int(BLEAdvParameterUpdated)]
+}
+
+// AllHistoryTypes returns all defined history types in order.
+func AllHistoryTypes() []HistoryType {
+	types := make([]HistoryType, len(historyTypeNames))
+	for i := range historyTypeNames {
+		types[i] = HistoryType(i)
+	}
+	return types
+}
+
+func (i HistoryType) String() string {
+	if i < 0 || int(i) >= len(historyTypeNames) {
+		return "HistoryType(" + strconv.Itoa(int(i)) + ")"
+	}
+	return historyTypeNames[i]
+}
+
 // History API
 // https://doc.candyhouse.co/ja/SesameAPI#sesame%E3%81%AE%E5%B1%A5%E6%AD%B4%E3%82%92%E5%8F%96%E5%BE%97
 // The server responses when UUID is not found or invalid. UUID string must be upper case.
 func (c *Client) History(ctx context.Context, uuid string, page, maxResults int) (*HistoryResponse, error) {
+	if err := c.validate(ctx, uuid); err != nil {
+		return nil, fmt.Errorf("sesame: History: %w", err)
+	}
+	if err := validatePaging(page, maxResults); err != nil {
+		return nil, fmt.Errorf("sesame: History: %w", err)
+	}
+
 	q := url.Values{}
 	q.Add("page", strconv.Itoa(page))
 	q.Add("lg", strconv.Itoa(maxResults))
 
-	endpoint := c.Endpoint
-	if endpoint == "" {
-		endpoint = DefaultEndpoint
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/"+uuid+"?"+q.Encode(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.deviceURL(uuid)+"?"+q.Encode(), nil)
 	if err != nil {
-		return nil, fmt.Errorf("creating new HTTP request: %w", err)
+		return nil, fmt.Errorf("sesame: History: creating new HTTP request: %w", err)
 	}
 
-	req.Header.Add("x-api-key", c.APIKey)
+	req.Header.Add(c.apiKeyHeader(), c.apiKey(ctx))
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do("History", req)
 	if err != nil {
-		return nil, fmt.Errorf("doing HTTP request: %w", err)
+		return nil, fmt.Errorf("sesame: History: doing HTTP request: %w", err)
 	}
 	defer func() {
 		_, _ = io.Copy(io.Discard, resp.Body)
@@ -138,13 +783,634 @@ func (c *Client) History(ctx context.Context, uuid string, page, maxResults int)
 	}()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected HTTP status: %s", resp.Status)
+		return nil, fmt.Errorf("sesame: History: %w", newHTTPError(resp))
 	}
 
 	var hist HistoryResponse
-	if err := json.NewDecoder(resp.Body).Decode(&hist); err != nil {
-		return nil, fmt.Errorf("decoding response body: %w", err)
+	if err := c.decode(resp.Body, &hist); err != nil {
+		return nil, fmt.Errorf("sesame: History: decoding response body: %w", err)
 	}
 
 	return &hist, nil
 }
+
+// the maximum number of times HistoryNegotiated halves the page size.
+// 400 Bad Request doesn't tell whether the page size is the cause,
+// so this bounds the extra requests when the request is rejected for other reasons.
+const maxPageSizeNegotiations = 3
+
+// HistoryNegotiated is same as History, but when the server rejects maxResults with 400 Bad Request,
+// this retries with a halved page size up to three times, or until it becomes 1.
+// The page size actually used is returned with the response.
+// Note that page is interpreted with the page size actually used.
+func (c *Client) HistoryNegotiated(ctx context.Context, uuid string, page, maxResults int) (*HistoryResponse, int, error) {
+	for negotiations := 0; ; negotiations++ {
+		hist, err := c.History(ctx, uuid, page, maxResults)
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusBadRequest &&
+			maxResults > 1 && negotiations < maxPageSizeNegotiations {
+			maxResults /= 2
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		return hist, maxResults, nil
+	}
+}
+
+// LastActivity returns the timestamp of the most recent record in given history pages.
+// false will be returned when pages is empty.
+func LastActivity(pages []HistoryPage) (time.Time, bool) {
+	var last time.Time
+	for _, page := range pages {
+		if page.Timestamp.After(last) {
+			last = page.Timestamp
+		}
+	}
+	return last, len(pages) > 0
+}
+
+// TimeSinceLastActivity fetches the latest history record and returns the elapsed time since then.
+// false will be returned when the device has no history.
+func (c *Client) TimeSinceLastActivity(ctx context.Context, uuid string) (time.Duration, bool, error) {
+	if err := c.validate(ctx, uuid); err != nil {
+		return 0, false, fmt.Errorf("sesame: TimeSinceLastActivity: %w", err)
+	}
+
+	hist, err := c.History(ctx, uuid, 0, 1)
+	if err != nil {
+		return 0, false, fmt.Errorf("sesame: TimeSinceLastActivity: %w", err)
+	}
+
+	last, ok := LastActivity(hist.Pages)
+	if !ok {
+		return 0, false, nil
+	}
+
+	return c.now().Sub(last), true, nil
+}
+
+// command code for Sesame Bot
+const cmdClick = 89
+
+type commandRequest struct {
+	Cmd     int    `json:"cmd"`
+	History string `json:"history"`
+	Sign    string `json:"sign"`
+}
+
+// Click API
+// https://doc.candyhouse.co/ja/SesameAPI
+// This API is for Sesame Bot devices, not for Sesame locks.
+// secretKey is the hex encoded secret key of the device, and historyTag will be recorded in the device history.
+func (c *Client) Click(ctx context.Context, uuid, secretKey, historyTag string) error {
+	if err := c.validate(ctx, uuid); err != nil {
+		return fmt.Errorf("sesame: Click: %w", err)
+	}
+	if err := validateSecretKey(secretKey); err != nil {
+		return fmt.Errorf("sesame: Click: %w", err)
+	}
+
+	key, err := decodeSecretKey(secretKey)
+	if err != nil {
+		return fmt.Errorf("sesame: Click: %w", err)
+	}
+
+	if err := c.command(ctx, "Click", uuid, cmdClick, key, historyTag); err != nil {
+		return fmt.Errorf("sesame: Click: %w", err)
+	}
+	return nil
+}
+
+// ClickWithKey is same as Click, but accepts the raw 16 bytes secret key instead of hex encoded one.
+func (c *Client) ClickWithKey(ctx context.Context, uuid string, secretKey []byte, historyTag string) error {
+	if err := c.validate(ctx, uuid); err != nil {
+		return fmt.Errorf("sesame: ClickWithKey: %w", err)
+	}
+
+	if err := c.command(ctx, "ClickWithKey", uuid, cmdClick, secretKey, historyTag); err != nil {
+		return fmt.Errorf("sesame: ClickWithKey: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) command(ctx context.Context, op, uuid string, cmd int, secretKey []byte, historyTag string) error {
+	if c.CommandPreconditions && !c.DryRun {
+		status, err := c.Status(ctx, uuid)
+		if err != nil {
+			return fmt.Errorf("checking preconditions: %w", err)
+		}
+		if status.BatteryPercentage < c.minCommandBattery() {
+			return fmt.Errorf("%w: %d%%", ErrBatteryTooLow, status.BatteryPercentage)
+		}
+	}
+
+	signature, err := sign(secretKey, c.now())
+	if err != nil {
+		return fmt.Errorf("signing command: %w", err)
+	}
+
+	body, err := json.Marshal(commandRequest{
+		Cmd:     cmd,
+		History: base64.StdEncoding.EncodeToString([]byte(historyTag)),
+		Sign:    signature,
+	})
+	if err != nil {
+		return fmt.Errorf("encoding request body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.deviceURL(uuid)+"/cmd", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating new HTTP request: %w", err)
+	}
+
+	req.Header.Add(c.apiKeyHeader(), c.apiKey(ctx))
+	req.Header.Add("Content-Type", "application/json")
+
+	if c.DryRun {
+		return nil
+	}
+
+	resp, err := c.do(op, req)
+	if err != nil {
+		return fmt.Errorf("doing HTTP request: %w", err)
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return newHTTPError(resp)
+	}
+
+	return nil
+}
+
+// decodeSecretKey decodes the hex encoded secret key.
+func decodeSecretKey(secretKey string) ([]byte, error) {
+	key, err := hex.DecodeString(secretKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSecretKey, err)
+	}
+	return key, nil
+}
+
+// sign generates the signature for command APIs,
+// which is AES-CMAC of the little endian unix time without its first byte.
+func sign(secretKey []byte, t time.Time) (string, error) {
+	if len(secretKey) != 16 {
+		return "", fmt.Errorf("%w: must be 16 bytes but %d bytes", ErrInvalidSecretKey, len(secretKey))
+	}
+
+	var msg [4]byte
+	binary.LittleEndian.PutUint32(msg[:], uint32(t.Unix()))
+
+	mac, err := cmac(secretKey, msg[1:])
+	if err != nil {
+		return "", fmt.Errorf("calculating AES-CMAC: %w", err)
+	}
+
+	return hex.EncodeToString(mac), nil
+}
+
+type Overview struct {
+	Status  *StatusResponse
+	History []HistoryPage
+}
+
+// Overview fetches the status and the most recent history records of the device concurrently.
+// Even when one of them fails, the result of the other one is returned with the error,
+// so that a history failure doesn't hide the status.
+func (c *Client) Overview(ctx context.Context, uuid string, recent int) (*Overview, error) {
+	if err := c.validate(ctx, uuid); err != nil {
+		return nil, fmt.Errorf("sesame: Overview: %w", err)
+	}
+	if recent <= 0 {
+		return nil, fmt.Errorf("sesame: Overview: %w", &ValidationError{Field: "recent", Reason: "must be positive"})
+	}
+
+	var (
+		overview              Overview
+		statusErr, historyErr error
+		wg                    sync.WaitGroup
+	)
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		overview.Status, statusErr = c.Status(ctx, uuid)
+	}()
+	go func() {
+		defer wg.Done()
+		hist, err := c.History(ctx, uuid, 0, recent)
+		if err != nil {
+			historyErr = err
+			return
+		}
+		overview.History = hist.Pages
+	}()
+	wg.Wait()
+
+	switch {
+	case statusErr != nil && historyErr != nil:
+		return &overview, fmt.Errorf("sesame: Overview: fetching status: %w (fetching history: %v)", statusErr, historyErr)
+	case statusErr != nil:
+		return &overview, fmt.Errorf("sesame: Overview: fetching status: %w", statusErr)
+	case historyErr != nil:
+		return &overview, fmt.Errorf("sesame: Overview: fetching history: %w", historyErr)
+	}
+
+	return &overview, nil
+}
+
+// StatusWithActor fetches the status and the most recent history record of the device concurrently,
+// and returns the status with the actor who operated the device last.
+// The actor will be empty when the device has no history.
+// When only the history fails, the status is returned with the empty actor and the error.
+func (c *Client) StatusWithActor(ctx context.Context, uuid string) (*StatusResponse, string, error) {
+	overview, err := c.Overview(ctx, uuid, 1)
+	if err != nil {
+		if overview == nil {
+			return nil, "", err
+		}
+		return overview.Status, "", err
+	}
+
+	if len(overview.History) == 0 {
+		return overview.Status, "", nil
+	}
+	return overview.Status, overview.History[0].Actor(), nil
+}
+
+// nilUUID is used to make an authenticated request without any device.
+const nilUUID = "00000000-0000-0000-0000-000000000000"
+
+// VerifyAPIKey checks whether the API key is accepted by the server.
+// ErrUnauthorized will be returned when the server responses 401 Unauthorized or 403 Forbidden.
+// There's no API which doesn't need any device, so this requests the status of a non-existent device.
+// The server responses internal server error for unknown UUID, so 500 is treated as success as well as 2xx, 400 and 404,
+// and other statuses like 429 Too Many Requests or 503 Service Unavailable are returned as *HTTPError.
+func (c *Client) VerifyAPIKey(ctx context.Context) error {
+	if err := c.validateAPIKey(ctx); err != nil {
+		return fmt.Errorf("sesame: VerifyAPIKey: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.deviceURL(nilUUID), nil)
+	if err != nil {
+		return fmt.Errorf("sesame: VerifyAPIKey: creating new HTTP request: %w", err)
+	}
+
+	req.Header.Add(c.apiKeyHeader(), c.apiKey(ctx))
+
+	resp, err := c.do("VerifyAPIKey", req)
+	if err != nil {
+		return fmt.Errorf("sesame: VerifyAPIKey: doing HTTP request: %w", err)
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	switch code := resp.StatusCode; {
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return fmt.Errorf("sesame: VerifyAPIKey: %w", ErrUnauthorized)
+	case 200 <= code && code < 300,
+		code == http.StatusBadRequest,
+		code == http.StatusNotFound,
+		code == http.StatusInternalServerError:
+		return nil
+	}
+
+	return fmt.Errorf("sesame: VerifyAPIKey: %w", newHTTPError(resp))
+}
+
+// HasAutoLocked reports whether pages contain an AutoLock record after since.
+func HasAutoLocked(pages []HistoryPage, since time.Time) bool {
+	for _, page := range pages {
+		if page.Type == AutoLock && page.Timestamp.After(since) {
+			return true
+		}
+	}
+	return false
+}
+
+// HistoryOldestFirst fetches all history records of the device and returns them in chronological order.
+// The server returns records newest first and the total page count is not available,
+// so this needs to fetch all pages before returning any records.
+func (c *Client) HistoryOldestFirst(ctx context.Context, uuid string, maxResults int) ([]HistoryPage, error) {
+	if err := c.validate(ctx, uuid); err != nil {
+		return nil, fmt.Errorf("sesame: HistoryOldestFirst: %w", err)
+	}
+	if err := validatePaging(0, maxResults); err != nil {
+		return nil, fmt.Errorf("sesame: HistoryOldestFirst: %w", err)
+	}
+
+	pages, err := c.fetchAllHistory(ctx, uuid, maxResults)
+	if err != nil {
+		return nil, fmt.Errorf("sesame: HistoryOldestFirst: %w", err)
+	}
+
+	for i, j := 0, len(pages)-1; i < j; i, j = i+1, j-1 {
+		pages[i], pages[j] = pages[j], pages[i]
+	}
+	return pages, nil
+}
+
+// EstimateHistoryPages returns the number of History API calls needed to fetch totalRecords records.
+// When totalRecords is a multiple of pageSize, one more call is counted since paging stops at a short page.
+func EstimateHistoryPages(totalRecords, pageSize int) int {
+	if totalRecords < 0 || pageSize <= 0 {
+		return 0
+	}
+	return totalRecords/pageSize + 1
+}
+
+// fetchAllHistory fetches history pages until the server returns a page shorter than maxResults.
+func (c *Client) fetchAllHistory(ctx context.Context, uuid string, maxResults int) ([]HistoryPage, error) {
+	var pages []HistoryPage
+	for page, err := range c.HistorySeq(ctx, uuid, maxResults) {
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, page)
+	}
+	return pages, nil
+}
+
+// NewRecords returns the records in current which are not in previous, compared by RecordID.
+// The order of current is kept.
+func NewRecords(previous, current []HistoryPage) []HistoryPage {
+	seen := make(map[int]struct{}, len(previous))
+	for _, page := range previous {
+		seen[page.RecordID] = struct{}{}
+	}
+
+	var records []HistoryPage
+	for _, page := range current {
+		if _, ok := seen[page.RecordID]; !ok {
+			records = append(records, page)
+		}
+	}
+	return records
+}
+
+const defaultPageSize = 50
+
+func (c *Client) pageSize() int {
+	if c.DefaultPageSize <= 0 {
+		return defaultPageSize
+	}
+	return c.DefaultPageSize
+}
+
+// HistoryDefault is same as History, but uses DefaultPageSize as maxResults.
+func (c *Client) HistoryDefault(ctx context.Context, uuid string, page int) (*HistoryResponse, error) {
+	return c.History(ctx, uuid, page, c.pageSize())
+}
+
+// HistoryByRecordRange fetches the history records whose RecordID is between minID and maxID, inclusive.
+// Since the server returns records newest first, this stops paging once it reaches a record older than minID.
+func (c *Client) HistoryByRecordRange(ctx context.Context, uuid string, minID, maxID int) ([]HistoryPage, error) {
+	if err := c.validate(ctx, uuid); err != nil {
+		return nil, fmt.Errorf("sesame: HistoryByRecordRange: %w", err)
+	}
+	if minID > maxID {
+		return nil, fmt.Errorf("sesame: HistoryByRecordRange: %w", &ValidationError{Field: "minID", Reason: "must not be greater than maxID"})
+	}
+
+	var pages []HistoryPage
+	for record, err := range c.HistorySeq(ctx, uuid, c.pageSize()) {
+		if err != nil {
+			return nil, fmt.Errorf("sesame: HistoryByRecordRange: %w", err)
+		}
+		if record.RecordID < minID {
+			break
+		}
+		if record.RecordID <= maxID {
+			pages = append(pages, record)
+		}
+	}
+	return pages, nil
+}
+
+// GroupHistoryByDay groups the history records by the calendar day in loc, keyed by YYYY-MM-DD.
+// UTC is used when loc is nil.
+func GroupHistoryByDay(pages []HistoryPage, loc *time.Location) map[string][]HistoryPage {
+	if loc == nil {
+		loc = time.UTC
+	}
+	days := map[string][]HistoryPage{}
+	for _, page := range pages {
+		day := page.Timestamp.In(loc).Format("2006-01-02")
+		days[day] = append(days[day], page)
+	}
+	return days
+}
+
+func (c *Client) minCommandBattery() int {
+	if c.MinCommandBattery <= 0 {
+		return DefaultMinCommandBattery
+	}
+	return c.MinCommandBattery
+}
+
+func (c *Client) minPollInterval() time.Duration {
+	if c.MinPollInterval <= 0 {
+		return DefaultMinPollInterval
+	}
+	return c.MinPollInterval
+}
+
+func (c *Client) pollInterval(interval time.Duration) time.Duration {
+	if min := c.minPollInterval(); interval < min {
+		return min
+	}
+	return interval
+}
+
+// WaitForBattery polls the status of the device every pollInterval
+// until the battery percentage becomes minPercent or more, or ctx is done.
+// This is useful to confirm the new reading after replacing batteries.
+// pollInterval smaller than MinPollInterval is clamped.
+func (c *Client) WaitForBattery(ctx context.Context, uuid string, minPercent int, pollInterval time.Duration) (*StatusResponse, error) {
+	if err := c.validate(ctx, uuid); err != nil {
+		return nil, fmt.Errorf("sesame: WaitForBattery: %w", err)
+	}
+	if minPercent < 0 || 100 < minPercent {
+		return nil, fmt.Errorf("sesame: WaitForBattery: %w", &ValidationError{Field: "minPercent", Reason: "must be between 0 and 100"})
+	}
+	if pollInterval <= 0 {
+		return nil, fmt.Errorf("sesame: WaitForBattery: %w", &ValidationError{Field: "pollInterval", Reason: "must be positive"})
+	}
+
+	interval := c.pollInterval(pollInterval)
+	for {
+		status, err := c.Status(ctx, uuid)
+		if err != nil {
+			return nil, fmt.Errorf("sesame: WaitForBattery: %w", err)
+		}
+		if status.BatteryPercentage >= minPercent {
+			return status, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("sesame: WaitForBattery: %w", ctx.Err())
+		case <-c.after(interval):
+		}
+	}
+}
+
+// IsLock reports whether the history type is a lock event,
+// including BLE, auto, manual and drive ones.
+func (i HistoryType) IsLock() bool {
+	switch i {
+	case BLELock, AutoLock, ManualLocked, DriveLocked:
+		return true
+	}
+	return false
+}
+
+// IsUnlock reports whether the history type is an unlock event,
+// including BLE, manual and drive ones.
+func (i HistoryType) IsUnlock() bool {
+	switch i {
+	case BLEUnlock, ManualUnlocked, DriveUnlocked:
+		return true
+	}
+	return false
+}
+
+// LastLockUnlock returns the timestamps of the most recent lock event and unlock event respectively.
+// The zero time is returned for the missing one, and ok is false when both are missing.
+func LastLockUnlock(pages []HistoryPage) (lastLock, lastUnlock time.Time, ok bool) {
+	for _, page := range pages {
+		switch {
+		case page.Type.IsLock() && page.Timestamp.After(lastLock):
+			lastLock = page.Timestamp
+			ok = true
+		case page.Type.IsUnlock() && page.Timestamp.After(lastUnlock):
+			lastUnlock = page.Timestamp
+			ok = true
+		}
+	}
+	return lastLock, lastUnlock, ok
+}
+
+// FilterHistory returns the records whose type is one of types.
+func FilterHistory(pages []HistoryPage, types ...HistoryType) []HistoryPage {
+	var filtered []HistoryPage
+	for _, page := range pages {
+		for _, typ := range types {
+			if page.Type == typ {
+				filtered = append(filtered, page)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// ManualOperations returns the records of physical operations on the device,
+// which are ManualLocked, ManualUnlocked and ManualElse.
+func ManualOperations(pages []HistoryPage) []HistoryPage {
+	return FilterHistory(pages, ManualLocked, ManualUnlocked, ManualElse)
+}
+
+type BatterySample struct {
+	Percent int
+	Voltage float64
+	At      time.Time
+}
+
+// the number of errors BatterySamples keeps until they are received.
+const batterySampleErrorBuffer = 8
+
+// BatterySamples polls the status of the device every interval and emits its battery readings.
+// Errors on polling are emitted to the error channel and polling continues.
+// Both channels are closed when ctx is done. interval smaller than MinPollInterval is clamped.
+// When uuid or interval is invalid, the validation error is emitted and both channels are closed without polling.
+//
+// Callers must keep receiving from the sample channel until it's closed or cancel ctx, since polling blocks
+// until the sample is received. The error channel is buffered, and errors are dropped while the buffer is full,
+// so that an unread error channel never stalls polling.
+func (c *Client) BatterySamples(ctx context.Context, uuid string, interval time.Duration) (<-chan BatterySample, <-chan error) {
+	samples := make(chan BatterySample)
+	errs := make(chan error, batterySampleErrorBuffer)
+
+	err := c.validate(ctx, uuid)
+	if err == nil && interval <= 0 {
+		err = &ValidationError{Field: "interval", Reason: "must be positive"}
+	}
+	if err != nil {
+		errs <- fmt.Errorf("sesame: BatterySamples: %w", err)
+		close(samples)
+		close(errs)
+		return samples, errs
+	}
+
+	go func() {
+		defer close(samples)
+		defer close(errs)
+
+		interval := c.pollInterval(interval)
+		for {
+			status, err := c.Status(ctx, uuid)
+			if err != nil {
+				select {
+				case errs <- fmt.Errorf("sesame: BatterySamples: %w", err):
+				default:
+				}
+			} else {
+				sample := BatterySample{
+					Percent: status.BatteryPercentage,
+					Voltage: status.BatteryVoltage,
+					At:      c.now(),
+				}
+				select {
+				case samples <- sample:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-c.after(interval):
+			}
+		}
+	}()
+
+	return samples, errs
+}
+
+// EqualUUID reports whether a and b are the same UUID,
+// ignoring cases, surrounding braces and whitespaces.
+func EqualUUID(a, b string) bool {
+	return normalizeUUID(a) == normalizeUUID(b)
+}
+
+func normalizeUUID(uuid string) string {
+	uuid = strings.TrimSpace(uuid)
+	uuid = strings.TrimPrefix(uuid, "{")
+	uuid = strings.TrimSuffix(uuid, "}")
+	return strings.ToUpper(strings.TrimSpace(uuid))
+}
+
+// DistinctHistoryTypes returns the sorted unique history types present in pages.
+func DistinctHistoryTypes(pages []HistoryPage) []HistoryType {
+	seen := map[HistoryType]struct{}{}
+	var types []HistoryType
+	for _, page := range pages {
+		if _, ok := seen[page.Type]; ok {
+			continue
+		}
+		seen[page.Type] = struct{}{}
+		types = append(types, page.Type)
+	}
+	slices.Sort(types)
+	return types
+}
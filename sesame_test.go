@@ -0,0 +1,1532 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testAPIKey = "test-api-key"
+
+// newTestClient returns a client requesting to a test server serving handler.
+func newTestClient(t *testing.T, handler http.Handler) *Client {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	return &Client{
+		Endpoint: srv.URL,
+		APIKey:   testAPIKey,
+	}
+}
+
+func TestClick(t *testing.T) {
+	const secretKey = "000102030405060708090a0b0c0d0e0f"
+	now := time.Unix(0x12345678, 0)
+
+	var got commandRequest
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("unexpected method: %s", r.Method)
+		}
+		if r.URL.Path != "/DEVICE/cmd" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if key := r.Header.Get("x-api-key"); key != testAPIKey {
+			t.Errorf("unexpected API key: %s", key)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding request body: %v", err)
+		}
+	}))
+	c.Now = func() time.Time { return now }
+
+	if err := c.Click(context.Background(), "DEVICE", secretKey, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := commandRequest{
+		Cmd:     89,
+		History: "YWxpY2U=",
+		Sign:    "c36e8692c90c3db71c8abf3d736d6d76",
+	}
+	if got != want {
+		t.Errorf("unexpected request body:\n  got:  %+v\n  want: %+v", got, want)
+	}
+}
+
+func TestVerifyAPIKey(t *testing.T) {
+	tests := []struct {
+		name             string
+		apiKey           string
+		unknownDevice    int
+		wantUnauthorized bool
+		wantHTTPError    bool
+	}{
+		{name: "valid key", apiKey: testAPIKey, unknownDevice: http.StatusInternalServerError},
+		{name: "invalid key", apiKey: "invalid", unknownDevice: http.StatusInternalServerError, wantUnauthorized: true},
+		{name: "rate limited", apiKey: testAPIKey, unknownDevice: http.StatusTooManyRequests, wantHTTPError: true},
+		{name: "gateway down", apiKey: testAPIKey, unknownDevice: http.StatusBadGateway, wantHTTPError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("x-api-key") != testAPIKey {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				w.WriteHeader(tt.unknownDevice)
+			}))
+			c.APIKey = tt.apiKey
+
+			err := c.VerifyAPIKey(context.Background())
+			var httpErr *HTTPError
+			switch {
+			case tt.wantUnauthorized:
+				if !errors.Is(err, ErrUnauthorized) {
+					t.Errorf("ErrUnauthorized is expected but got: %v", err)
+				}
+			case tt.wantHTTPError:
+				if !errors.As(err, &httpErr) || httpErr.StatusCode != tt.unknownDevice {
+					t.Errorf("HTTPError with %d is expected but got: %v", tt.unknownDevice, err)
+				}
+			case err != nil:
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestErrorPrefix(t *testing.T) {
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	ctx := context.Background()
+
+	tests := []struct {
+		op string
+		fn func() error
+	}{
+		{"CheckHealth", func() error { _, err := c.CheckHealth(ctx, "DEVICE"); return err }},
+		{"StatusChangedSince", func() error { _, _, err := c.StatusChangedSince(ctx, "DEVICE", &StatusResponse{}); return err }},
+		{"StreamHistoryFiltered", func() error {
+			return c.StreamHistoryFiltered(ctx, "DEVICE", AllHistoryTypes(), func(HistoryPage) error { return nil })
+		}},
+		{"TooManyDriveFailures", func() error { _, err := c.TooManyDriveFailures(ctx, "DEVICE", time.Hour, 1); return err }},
+		{"Overview", func() error { _, err := c.Overview(ctx, "DEVICE", 1); return err }},
+		{"Status", func() error { _, err := c.Status(ctx, "DEVICE"); return err }},
+		{"History", func() error { _, err := c.History(ctx, "DEVICE", 0, 10); return err }},
+		{"TimeSinceLastActivity", func() error { _, _, err := c.TimeSinceLastActivity(ctx, "DEVICE"); return err }},
+		{"WaitForBattery", func() error { _, err := c.WaitForBattery(ctx, "DEVICE", 50, time.Second); return err }},
+		{"HistoryOldestFirst", func() error { _, err := c.HistoryOldestFirst(ctx, "DEVICE", 10); return err }},
+		{"HistoryByRecordRange", func() error { _, err := c.HistoryByRecordRange(ctx, "DEVICE", 1, 10); return err }},
+		{"SyncHistory", func() error { _, err := c.SyncHistory(ctx, "DEVICE", memoryCursorStore{}); return err }},
+		{"BatterySamples", func() error {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			_, errs := c.BatterySamples(ctx, "DEVICE", time.Second)
+			return <-errs
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.op, func(t *testing.T) {
+			err := tt.fn()
+			if err == nil {
+				t.Fatal("error is expected but got nil")
+			}
+			if prefix := "sesame: " + tt.op + ": "; !strings.HasPrefix(err.Error(), prefix) {
+				t.Errorf("error should start with %q: %v", prefix, err)
+			}
+		})
+	}
+}
+
+func TestValidationBeforeRequest(t *testing.T) {
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+	}))
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		field string
+		fn    func() error
+	}{
+		{"HistorySeq/uuid", "uuid", func() error {
+			for _, err := range c.HistorySeq(ctx, "", 10) {
+				return err
+			}
+			return nil
+		}},
+		{"HistorySeq/pageSize", "maxResults", func() error {
+			for _, err := range c.HistorySeq(ctx, "DEVICE", 0) {
+				return err
+			}
+			return nil
+		}},
+		{"WaitForBattery/uuid", "uuid", func() error {
+			_, err := c.WaitForBattery(ctx, "../x", 50, time.Second)
+			return err
+		}},
+		{"WaitForBattery/minPercent", "minPercent", func() error {
+			_, err := c.WaitForBattery(ctx, "DEVICE", 101, time.Second)
+			return err
+		}},
+		{"BatterySamples/uuid", "uuid", func() error {
+			_, errs := c.BatterySamples(ctx, "", time.Second)
+			return <-errs
+		}},
+		{"BatterySamples/interval", "interval", func() error {
+			_, errs := c.BatterySamples(ctx, "DEVICE", 0)
+			return <-errs
+		}},
+		{"SyncHistory/store", "store", func() error {
+			_, err := c.SyncHistory(ctx, "DEVICE", nil)
+			return err
+		}},
+		{"Status/APIKey", "APIKey", func() error {
+			_, err := (&Client{Endpoint: c.Endpoint}).Status(ctx, "DEVICE")
+			return err
+		}},
+		{"History/page", "page", func() error {
+			_, err := c.History(ctx, "DEVICE", -1, 10)
+			return err
+		}},
+		{"History/maxResults", "maxResults", func() error {
+			_, err := c.History(ctx, "DEVICE", 0, 0)
+			return err
+		}},
+		{"Click/secretKey", "secretKey", func() error {
+			return c.Click(ctx, "DEVICE", "", "alice")
+		}},
+		{"HistoryByRecordRange/minID", "minID", func() error {
+			_, err := c.HistoryByRecordRange(ctx, "DEVICE", 10, 1)
+			return err
+		}},
+		{"Overview/recent", "recent", func() error {
+			_, err := c.Overview(ctx, "DEVICE", 0)
+			return err
+		}},
+		{"TimeSinceLastActivity/uuid", "uuid", func() error {
+			_, _, err := c.TimeSinceLastActivity(ctx, "")
+			return err
+		}},
+		{"StreamHistoryFiltered/fn", "fn", func() error {
+			return c.StreamHistoryFiltered(ctx, "DEVICE", AllHistoryTypes(), nil)
+		}},
+		{"TooManyDriveFailures/within", "within", func() error {
+			_, err := c.TooManyDriveFailures(ctx, "DEVICE", 0, 1)
+			return err
+		}},
+		{"TooManyDriveFailures/threshold", "threshold", func() error {
+			_, err := c.TooManyDriveFailures(ctx, "DEVICE", time.Hour, -1)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var verr *ValidationError
+			if err := tt.fn(); !errors.As(err, &verr) || verr.Field != tt.field {
+				t.Errorf("ValidationError for %s is expected but got: %v", tt.field, err)
+			}
+		})
+	}
+}
+
+func TestHistoryTypeString(t *testing.T) {
+	for _, typ := range AllHistoryTypes() {
+		if name := typ.String(); name == "" || strings.HasPrefix(name, "HistoryType(") {
+			t.Errorf("history type %d has no name", int(typ))
+		}
+	}
+	if got, want := HistoryType(len(historyTypeNames)).String(), "HistoryType(14)"; got != want {
+		t.Errorf("unexpected name for unknown history type: %s != %s", got, want)
+	}
+}
+
+func TestGroupHistoryByDay(t *testing.T) {
+	jst := time.FixedZone("JST", 9*60*60)
+	pages := []HistoryPage{
+		{RecordID: 1, Timestamp: time.Date(2020, 1, 1, 14, 0, 0, 0, time.UTC)}, // 2020-01-01 23:00 JST
+		{RecordID: 2, Timestamp: time.Date(2020, 1, 1, 16, 0, 0, 0, time.UTC)}, // 2020-01-02 01:00 JST
+	}
+
+	tests := []struct {
+		name string
+		loc  *time.Location
+		want map[string][]int
+	}{
+		{"JST", jst, map[string][]int{"2020-01-01": {1}, "2020-01-02": {2}}},
+		{"nil", nil, map[string][]int{"2020-01-01": {1, 2}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := map[string][]int{}
+			for day, pages := range GroupHistoryByDay(pages, tt.loc) {
+				for _, page := range pages {
+					got[day] = append(got[day], page.RecordID)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unexpected groups: %v != %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWaitForBattery(t *testing.T) {
+	batteries := []int{10, 20, 60}
+	var requests int
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"batteryPercentage": %d}`, batteries[requests])
+		requests++
+	}))
+	var waits []time.Duration
+	c.After = func(d time.Duration) <-chan time.Time {
+		waits = append(waits, d)
+		ch := make(chan time.Time, 1)
+		ch <- time.Time{}
+		return ch
+	}
+	c.MinPollInterval = time.Minute
+
+	status, err := c.WaitForBattery(context.Background(), "DEVICE", 50, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.BatteryPercentage != 60 {
+		t.Errorf("unexpected battery percentage: %d", status.BatteryPercentage)
+	}
+	if want := []time.Duration{time.Minute, time.Minute}; !reflect.DeepEqual(waits, want) {
+		t.Errorf("unexpected waits: %v != %v", waits, want)
+	}
+}
+
+func TestIsProbablyOffline(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	status := StatusResponse{Timestamp: now.Add(-10 * time.Minute)}
+	if status.IsProbablyOffline(time.Hour, now) {
+		t.Error("10 minutes old status should not be offline with 1 hour max age")
+	}
+	if !status.IsProbablyOffline(time.Minute, now) {
+		t.Error("10 minutes old status should be offline with 1 minute max age")
+	}
+}
+
+func TestStatusRejectsPathTraversal(t *testing.T) {
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request: %s", r.URL)
+	}))
+
+	for _, uuid := range []string{"../x", "..", ".", "a/b", "a?b", "a#b", "a%2Fb"} {
+		t.Run(uuid, func(t *testing.T) {
+			var verr *ValidationError
+			if _, err := c.Status(context.Background(), uuid); !errors.As(err, &verr) || verr.Field != "uuid" {
+				t.Errorf("ValidationError for uuid is expected but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestDeviceURL(t *testing.T) {
+	c := &Client{Endpoint: "https://example.com/api"}
+	if got, want := c.deviceURL("abc-def"), "https://example.com/api/abc-def"; got != want {
+		t.Errorf("unexpected URL: %s != %s", got, want)
+	}
+	c.AutoUppercaseUUID = true
+	if got, want := c.deviceURL("abc-def"), "https://example.com/api/ABC-DEF"; got != want {
+		t.Errorf("unexpected URL: %s != %s", got, want)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"batteryPercentage": 42, "unknownField": true}`)
+	}))
+	c.StrictDecoding = true
+
+	if _, err := c.Status(context.Background(), "DEVICE"); err == nil {
+		t.Error("unknown field should be rejected with StrictDecoding")
+	}
+
+	var calls int
+	c.Decoder = func(r io.Reader, v interface{}) error {
+		calls++
+		return json.NewDecoder(r).Decode(v)
+	}
+	status, err := c.Status(context.Background(), "DEVICE")
+	if err != nil {
+		t.Fatalf("StrictDecoding should be ignored with Decoder: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Decoder should be called once but called %d times", calls)
+	}
+	if status.BatteryPercentage != 42 {
+		t.Errorf("unexpected battery percentage: %d", status.BatteryPercentage)
+	}
+
+	decodeErr := errors.New("decode error")
+	c.Decoder = func(io.Reader, interface{}) error { return decodeErr }
+	if _, err := c.Status(context.Background(), "DEVICE"); !errors.Is(err, decodeErr) {
+		t.Errorf("error of Decoder should be wrapped: %v", err)
+	}
+}
+
+func TestStatusWithActor(t *testing.T) {
+	tests := []struct {
+		name          string
+		historyStatus int
+		wantActor     string
+		wantErr       bool
+	}{
+		{name: "success", historyStatus: http.StatusOK, wantActor: "alice"},
+		{name: "history failure", historyStatus: http.StatusInternalServerError, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Has("page") {
+					w.WriteHeader(tt.historyStatus)
+					_, _ = io.WriteString(w, `{"Pages": [{"recordID": 1, "type": 2, "historyTag": "YWxpY2U="}]}`)
+					return
+				}
+				_, _ = io.WriteString(w, `{"batteryPercentage": 42}`)
+			}))
+
+			status, actor, err := c.StatusWithActor(context.Background(), "DEVICE")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if status == nil || status.BatteryPercentage != 42 {
+				t.Errorf("status should be returned: %+v", status)
+			}
+			if actor != tt.wantActor {
+				t.Errorf("unexpected actor: %q != %q", actor, tt.wantActor)
+			}
+		})
+	}
+}
+
+func TestConfigSummary(t *testing.T) {
+	c := &Client{
+		APIKey:        "secret-api-key",
+		Decompressors: map[string]Decompressor{"zstd": nil},
+	}
+	summary := c.ConfigSummary()
+
+	if strings.Contains(summary, c.APIKey) {
+		t.Errorf("API key is not redacted:\n%s", summary)
+	}
+	for _, line := range []string{
+		"Endpoint: " + DefaultEndpoint,
+		"APIKey: <redacted>",
+		"APIKeyHeader: " + DefaultAPIKeyHeader,
+		"CommandPreconditions: false",
+		"MinCommandBattery: 10",
+		"DefaultPageSize: 50",
+		"MinPollInterval: 1s",
+		"StatusCacheTTL: <disabled>",
+		"AcceptEncoding: zstd, gzip",
+		"MaxResponseHeaderBytes: 65536",
+		"Proxy: <environment>",
+	} {
+		if !strings.Contains(summary, line+"\n") {
+			t.Errorf("summary doesn't contain %q:\n%s", line, summary)
+		}
+	}
+}
+
+func TestBatterySamples(t *testing.T) {
+	var requests int
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests%2 == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, `{"batteryPercentage": %d}`, 100-requests)
+	}))
+	waits := make(chan time.Duration)
+	ticks := make(chan time.Time)
+	c.After = func(d time.Duration) <-chan time.Time {
+		waits <- d
+		return ticks
+	}
+	c.MinPollInterval = time.Minute
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	samples, errs := c.BatterySamples(ctx, "DEVICE", time.Second)
+
+	// the error channel is never read until the end, which must not stall polling
+	var got []int
+	for len(got) < 3 {
+		select {
+		case sample := <-samples:
+			got = append(got, sample.Percent)
+		case d := <-waits:
+			if d != time.Minute {
+				t.Errorf("unexpected interval: %s", d)
+			}
+			ticks <- time.Time{}
+		}
+	}
+	if want := []int{99, 97, 95}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected samples: %v != %v", got, want)
+	}
+
+	cancel()
+	go func() {
+		for range waits {
+		}
+	}()
+	for range samples {
+	}
+	var nerrs int
+	for range errs {
+		nerrs++
+	}
+	if nerrs != 2 {
+		t.Errorf("unexpected number of errors: %d", nerrs)
+	}
+}
+
+func TestCommandPreconditions(t *testing.T) {
+	const secretKey = "000102030405060708090a0b0c0d0e0f"
+	tests := []struct {
+		name         string
+		battery      int
+		dryRun       bool
+		wantErr      error
+		wantRequests []string
+	}{
+		{name: "enough battery", battery: 50, wantRequests: []string{"GET /DEVICE", "POST /DEVICE/cmd"}},
+		{name: "low battery", battery: 5, wantErr: ErrBatteryTooLow, wantRequests: []string{"GET /DEVICE"}},
+		{name: "dry run", battery: 5, dryRun: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []string
+			c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests = append(requests, r.Method+" "+r.URL.Path)
+				if r.Method == http.MethodGet {
+					fmt.Fprintf(w, `{"batteryPercentage": %d}`, tt.battery)
+				}
+			}))
+			c.CommandPreconditions = true
+			c.DryRun = tt.dryRun
+
+			if err := c.Click(context.Background(), "DEVICE", secretKey, "alice"); !errors.Is(err, tt.wantErr) {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(requests, tt.wantRequests) {
+				t.Errorf("unexpected requests: %v != %v", requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestAutoLockEnabled(t *testing.T) {
+	tests := []struct {
+		body        string
+		wantEnabled bool
+		wantKnown   bool
+		wantDelay   time.Duration
+	}{
+		{body: `{"autoLockSecond": 30}`, wantEnabled: true, wantKnown: true, wantDelay: 30 * time.Second},
+		{body: `{"autoLockSecond": 0}`, wantKnown: true},
+		{body: `{}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			var status StatusResponse
+			if err := json.Unmarshal([]byte(tt.body), &status); err != nil {
+				t.Fatal(err)
+			}
+			enabled, known := status.AutoLockEnabled()
+			if enabled != tt.wantEnabled || known != tt.wantKnown {
+				t.Errorf("unexpected result: (%t, %t) != (%t, %t)", enabled, known, tt.wantEnabled, tt.wantKnown)
+			}
+			if delay := status.AutoLockDelay(); delay != tt.wantDelay {
+				t.Errorf("unexpected delay: %s != %s", delay, tt.wantDelay)
+			}
+		})
+	}
+}
+
+func TestStatusFieldAliases(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantBattery int
+		wantState   State
+	}{
+		{"standard", `{"batteryPercentage": 10, "CHSesame2Status": "locked"}`, 10, Locked},
+		{"known alias", `{"battery": 20, "CHSesame2Status": "locked"}`, 20, Locked},
+		{"custom alias", `{"batteryPercentage": 30, "state": "unlocked"}`, 30, Unlocked},
+		{"both present", `{"battery": 40, "batteryPercentage": 50, "state": "unlocked", "CHSesame2Status": "locked"}`, 50, Locked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.WriteString(w, tt.body)
+			}))
+			c.StatusFieldAliases = map[string]string{"state": "CHSesame2Status"}
+			// aliases must be resolved before strict decoding sees them as unknown fields
+			c.StrictDecoding = true
+
+			status, err := c.Status(context.Background(), "DEVICE")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.BatteryPercentage != tt.wantBattery || status.Status != tt.wantState {
+				t.Errorf("unexpected status: (%d, %s) != (%d, %s)", status.BatteryPercentage, status.Status, tt.wantBattery, tt.wantState)
+			}
+			if string(status.Raw) != tt.body {
+				t.Errorf("Raw should keep the original body: %s", status.Raw)
+			}
+		})
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{
+			name: "valid",
+			body: `{"batteryPercentage": 100, "batteryVoltage": 5.85, "position": 11}`,
+		},
+		{
+			name: "out of range",
+			body: `{"batteryPercentage": 255, "batteryVoltage": -1, "position": 2048}`,
+			want: []string{
+				"battery percentage is out of range: 255",
+				"battery voltage is negative: -1.000000",
+				"position is out of range: 2048",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var status StatusResponse
+			if err := json.Unmarshal([]byte(tt.body), &status); err != nil {
+				t.Fatal(err)
+			}
+			if got := status.Warnings(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unexpected warnings: %q != %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLastActivity(t *testing.T) {
+	newest := time.Unix(1600000000, 0)
+	pages := []HistoryPage{
+		{RecordID: 2, Timestamp: newest.Add(-time.Hour)},
+		{RecordID: 3, Timestamp: newest},
+		{RecordID: 1, Timestamp: newest.Add(-2 * time.Hour)},
+	}
+	if last, ok := LastActivity(pages); !ok || !last.Equal(newest) {
+		t.Errorf("unexpected last activity: (%s, %t)", last, ok)
+	}
+	if last, ok := LastActivity(nil); ok || !last.IsZero() {
+		t.Errorf("no activity is expected for empty history: (%s, %t)", last, ok)
+	}
+}
+
+func TestTimeSinceLastActivity(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	tests := []struct {
+		name   string
+		body   string
+		want   time.Duration
+		wantOK bool
+	}{
+		{
+			name:   "populated",
+			body:   fmt.Sprintf(`{"Pages": [{"recordID": 1, "timestamp": %q}]}`, now.Add(-90*time.Second).Format(time.RFC3339)),
+			want:   90 * time.Second,
+			wantOK: true,
+		},
+		{
+			name: "empty",
+			body: `{"Pages": []}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.URL.Query().Get("lg"); got != "1" {
+					t.Errorf("only the latest record should be requested but lg=%s", got)
+				}
+				_, _ = io.WriteString(w, tt.body)
+			}))
+			c.Now = func() time.Time { return now }
+
+			got, ok, err := c.TimeSinceLastActivity(context.Background(), "DEVICE")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("unexpected result: (%s, %t) != (%s, %t)", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestOverview(t *testing.T) {
+	tests := []struct {
+		name          string
+		statusCode    int
+		historyCode   int
+		wantStatus    bool
+		wantHistory   []int
+		wantErrSubstr string
+	}{
+		{name: "success", statusCode: http.StatusOK, historyCode: http.StatusOK, wantStatus: true, wantHistory: []int{2, 1}},
+		{name: "history fails", statusCode: http.StatusOK, historyCode: http.StatusBadGateway, wantStatus: true, wantErrSubstr: "fetching history"},
+		{name: "status fails", statusCode: http.StatusBadGateway, historyCode: http.StatusOK, wantHistory: []int{2, 1}, wantErrSubstr: "fetching status"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Has("page") {
+					if got := r.URL.Query().Get("lg"); got != "2" {
+						t.Errorf("unexpected lg: %s", got)
+					}
+					w.WriteHeader(tt.historyCode)
+					_, _ = io.WriteString(w, `{"Pages": [{"recordID": 2}, {"recordID": 1}]}`)
+					return
+				}
+				w.WriteHeader(tt.statusCode)
+				_, _ = io.WriteString(w, `{"batteryPercentage": 42}`)
+			}))
+
+			overview, err := c.Overview(context.Background(), "DEVICE", 2)
+			if tt.wantErrSubstr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErrSubstr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErrSubstr)) {
+				t.Errorf("error containing %q is expected but got: %v", tt.wantErrSubstr, err)
+			}
+			if gotStatus := overview.Status != nil && overview.Status.BatteryPercentage == 42; gotStatus != tt.wantStatus {
+				t.Errorf("unexpected status: %+v", overview.Status)
+			}
+			var ids []int
+			for _, page := range overview.History {
+				ids = append(ids, page.RecordID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantHistory) {
+				t.Errorf("unexpected history: %v != %v", ids, tt.wantHistory)
+			}
+		})
+	}
+}
+
+func TestClickInvalidSecretKey(t *testing.T) {
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+	}))
+
+	tests := []struct {
+		name      string
+		secretKey string
+	}{
+		{"too short", "000102030405060708090a0b0c0d0e"},
+		{"too long", "000102030405060708090a0b0c0d0e0f10"},
+		{"not hex", "000102030405060708090a0b0c0d0e0g"},
+		{"odd length", "000102030405060708090a0b0c0d0e0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := c.Click(context.Background(), "DEVICE", tt.secretKey, "alice"); !errors.Is(err, ErrInvalidSecretKey) {
+				t.Errorf("ErrInvalidSecretKey is expected but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestClientTransport(t *testing.T) {
+	c := &Client{
+		MaxIdleConns:    3,
+		IdleConnTimeout: 42 * time.Second,
+		MaxConnsPerHost: 5,
+	}
+	transport, ok := c.client().Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("unexpected transport: %T", c.client().Transport)
+	}
+	if transport.MaxIdleConns != 3 || transport.MaxIdleConnsPerHost != 3 {
+		t.Errorf("MaxIdleConns is not applied: %d, %d", transport.MaxIdleConns, transport.MaxIdleConnsPerHost)
+	}
+	if transport.IdleConnTimeout != 42*time.Second {
+		t.Errorf("IdleConnTimeout is not applied: %s", transport.IdleConnTimeout)
+	}
+	if transport.MaxConnsPerHost != 5 {
+		t.Errorf("MaxConnsPerHost is not applied: %d", transport.MaxConnsPerHost)
+	}
+	if transport == http.DefaultTransport {
+		t.Error("http.DefaultTransport must not be modified")
+	}
+}
+
+func TestClientCustomHTTPClient(t *testing.T) {
+	custom := &http.Client{Timeout: time.Second}
+	c := &Client{
+		HTTPClient:      custom,
+		MaxIdleConns:    3,
+		IdleConnTimeout: 42 * time.Second,
+	}
+	if got := c.client(); got != custom {
+		t.Errorf("HTTPClient should be used as is: %p != %p", got, custom)
+	}
+	if custom.Transport != nil || custom.Timeout != time.Second {
+		t.Errorf("HTTPClient should not be modified: %+v", custom)
+	}
+}
+
+func TestMechStatusDecoding(t *testing.T) {
+	tests := []struct {
+		body string
+		want *MechStatus
+	}{
+		{`{"mechStatus": {"isInLockRange": true, "isInUnlockRange": false}}`, &MechStatus{InLockRange: true}},
+		{`{"mechStatus": {"isInLockRange": false, "isInUnlockRange": true}}`, &MechStatus{InUnlockRange: true}},
+		{`{"mechStatus": {"isInLockRange": false, "isInUnlockRange": false}}`, &MechStatus{}},
+		{`{}`, nil},
+	}
+	for _, tt := range tests {
+		var status StatusResponse
+		if err := json.Unmarshal([]byte(tt.body), &status); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(status.MechStatus, tt.want) {
+			t.Errorf("unexpected mechStatus for %s: %+v", tt.body, status.MechStatus)
+		}
+	}
+}
+
+func TestHasAutoLocked(t *testing.T) {
+	since := time.Unix(1600000000, 0)
+	tests := []struct {
+		name  string
+		pages []HistoryPage
+		want  bool
+	}{
+		{"auto lock after since", []HistoryPage{
+			{Type: ManualUnlocked, Timestamp: since.Add(time.Minute)},
+			{Type: AutoLock, Timestamp: since.Add(2 * time.Minute)},
+		}, true},
+		{"auto lock before since", []HistoryPage{
+			{Type: AutoLock, Timestamp: since.Add(-time.Minute)},
+			{Type: ManualLocked, Timestamp: since.Add(time.Minute)},
+		}, false},
+		{"auto lock at since", []HistoryPage{{Type: AutoLock, Timestamp: since}}, false},
+		{"empty", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HasAutoLocked(tt.pages, since); got != tt.want {
+				t.Errorf("unexpected result: %t != %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFields(t *testing.T) {
+	timestamp := time.Unix(1600000000, 0)
+	status := StatusResponse{
+		BatteryPercentage: 87,
+		BatteryVoltage:    5.9,
+		Position:          512,
+		Status:            Locked,
+		Timestamp:         timestamp,
+	}
+	want := map[string]interface{}{
+		"status":          "locked",
+		"battery_percent": 87,
+		"battery_voltage": 5.9,
+		"position":        512,
+		"timestamp":       timestamp,
+	}
+	// reflect.DeepEqual compares the dynamic types of the values as well
+	if got := status.Fields(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected fields:\n  got:  %#v\n  want: %#v", got, want)
+	}
+}
+
+// newRecordingProxy returns the URL of an HTTP proxy which records the requested URLs
+// and answers them with a status instead of forwarding.
+func newRecordingProxy(t *testing.T, requested *[]string) *url.URL {
+	t.Helper()
+
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		*requested = append(*requested, r.URL.String())
+		mu.Unlock()
+		_, _ = io.WriteString(w, `{"batteryPercentage": 42}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestClientProxy(t *testing.T) {
+	var requested []string
+	c := &Client{
+		Endpoint: "http://sesame.invalid/api",
+		APIKey:   testAPIKey,
+		Proxy:    newRecordingProxy(t, &requested),
+	}
+
+	if _, err := c.Status(context.Background(), "DEVICE"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"http://sesame.invalid/api/DEVICE"}; !reflect.DeepEqual(requested, want) {
+		t.Errorf("unexpected proxied requests: %v != %v", requested, want)
+	}
+}
+
+// The proxy environment variables are read only once per process, so this runs in a subprocess.
+func TestClientProxyFromEnvironment(t *testing.T) {
+	if proxy := os.Getenv("SESAME_TEST_PROXY"); proxy != "" {
+		t.Setenv("HTTP_PROXY", proxy)
+		c := &Client{Endpoint: "http://sesame.invalid/api", APIKey: testAPIKey}
+		if _, err := c.Status(context.Background(), "DEVICE"); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	var requested []string
+	cmd := exec.Command(os.Args[0], "-test.run=^TestClientProxyFromEnvironment$")
+	cmd.Env = append(os.Environ(), "SESAME_TEST_PROXY="+newRecordingProxy(t, &requested).String())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("subprocess failed: %v\n%s", err, out)
+	}
+	if want := []string{"http://sesame.invalid/api/DEVICE"}; !reflect.DeepEqual(requested, want) {
+		t.Errorf("unexpected proxied requests: %v != %v", requested, want)
+	}
+}
+
+func TestWiFiModuleStateDecoding(t *testing.T) {
+	tests := []struct {
+		body string
+		want *bool
+	}{
+		{`{"wm2State": true}`, func() *bool { v := true; return &v }()},
+		{`{"wm2State": false}`, func() *bool { v := false; return &v }()},
+		{`{}`, nil},
+	}
+	for _, tt := range tests {
+		var status StatusResponse
+		if err := json.Unmarshal([]byte(tt.body), &status); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(status.WiFiModuleState, tt.want) {
+			t.Errorf("unexpected wm2State for %s: %v", tt.body, status.WiFiModuleState)
+		}
+	}
+}
+
+func TestAPIKeyHeader(t *testing.T) {
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Proxy-Key"); got != testAPIKey {
+			t.Errorf("custom header should carry the API key: %q", got)
+		}
+		if got := r.Header.Values(DefaultAPIKeyHeader); len(got) != 0 {
+			t.Errorf("%s should not be sent: %q", DefaultAPIKeyHeader, got)
+		}
+		_, _ = io.WriteString(w, `{}`)
+	}))
+	c.APIKeyHeader = "X-Proxy-Key"
+
+	if _, err := c.Status(context.Background(), "DEVICE"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEstimatedBatteryDays(t *testing.T) {
+	tests := []struct {
+		voltage  float64
+		min, max int
+	}{
+		{6.20, 300, 300},
+		{6.00, 300, 300},
+		{5.85, 239, 240},
+		{5.775, 179, 180},
+		{5.60, 69, 70},
+		{5.30, 6, 7},
+		{5.20, 0, 0},
+		{4.00, 0, 0},
+	}
+	for _, tt := range tests {
+		status := StatusResponse{BatteryVoltage: tt.voltage}
+		if got := status.EstimatedBatteryDays(); got < tt.min || tt.max < got {
+			t.Errorf("unexpected days for %.3fV: %d is not in [%d, %d]", tt.voltage, got, tt.min, tt.max)
+		}
+	}
+
+	// more voltage never means less days
+	prev := 0
+	for v := 5.0; v <= 6.2; v += 0.01 {
+		days := (&StatusResponse{BatteryVoltage: v}).EstimatedBatteryDays()
+		if days < prev {
+			t.Errorf("days decreased at %.2fV: %d < %d", v, days, prev)
+		}
+		prev = days
+	}
+}
+
+func TestHistoryOldestFirst(t *testing.T) {
+	var requests []string
+	c := newTestClient(t, newHistoryServer(t, 5, 100, &requests))
+
+	pages, err := c.HistoryOldestFirst(context.Background(), "DEVICE", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, page := range pages {
+		ids = append(ids, page.RecordID)
+	}
+	if want := []int{1, 2, 3, 4, 5}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("unexpected records: %v != %v", ids, want)
+	}
+	if want := []string{"lg=2&page=0", "lg=2&page=1", "lg=2&page=2"}; !reflect.DeepEqual(requests, want) {
+		t.Errorf("unexpected requests: %v != %v", requests, want)
+	}
+}
+
+func TestNewRecords(t *testing.T) {
+	records := func(ids ...int) []HistoryPage {
+		pages := make([]HistoryPage, len(ids))
+		for i, id := range ids {
+			pages[i] = HistoryPage{RecordID: id}
+		}
+		return pages
+	}
+	tests := []struct {
+		name              string
+		previous, current []HistoryPage
+		want              []HistoryPage
+	}{
+		{"overlapping", records(3, 2, 1), records(5, 4, 3, 2), records(5, 4)},
+		{"fully new", records(2, 1), records(5, 4, 3), records(5, 4, 3)},
+		{"nothing new", records(3, 2, 1), records(3, 2), nil},
+		{"no previous", nil, records(2, 1), records(2, 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewRecords(tt.previous, tt.current); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unexpected records: %v != %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPositionFraction(t *testing.T) {
+	tests := []struct {
+		position int
+		want     float64
+	}{
+		{-5, 0},
+		{0, 0},
+		{MaxPosition / 2, float64(MaxPosition/2) / MaxPosition},
+		{MaxPosition, 1},
+		{MaxPosition + 100, 1},
+	}
+	for _, tt := range tests {
+		status := StatusResponse{Position: tt.position}
+		if got := status.PositionFraction(); got != tt.want {
+			t.Errorf("unexpected fraction for position %d: %g != %g", tt.position, got, tt.want)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request in dry-run mode: %s %s", r.Method, r.URL)
+	}))
+	c.DryRun = true
+	key := []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
+
+	if err := c.ClickWithKey(context.Background(), "DEVICE", key, "alice"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	// the command is still signed, so an invalid key is reported in dry-run mode as well
+	if err := c.ClickWithKey(context.Background(), "DEVICE", key[:15], "alice"); !errors.Is(err, ErrInvalidSecretKey) {
+		t.Errorf("ErrInvalidSecretKey is expected but got: %v", err)
+	}
+}
+
+func TestHistoryByRecordRange(t *testing.T) {
+	var requests []string
+	c := newTestClient(t, newHistoryServer(t, 10, 100, &requests))
+	c.DefaultPageSize = 3
+
+	pages, err := c.HistoryByRecordRange(context.Background(), "DEVICE", 4, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, page := range pages {
+		ids = append(ids, page.RecordID)
+	}
+	if want := []int{7, 6, 5, 4}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("unexpected records: %v != %v", ids, want)
+	}
+	// paging stops at the page containing a record older than minID
+	if want := []string{"lg=3&page=0", "lg=3&page=1", "lg=3&page=2"}; !reflect.DeepEqual(requests, want) {
+		t.Errorf("unexpected requests: %v != %v", requests, want)
+	}
+}
+
+func TestClientNow(t *testing.T) {
+	key := []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
+	now := time.Unix(0x12345678, 0)
+
+	var signs []string
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req commandRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		signs = append(signs, req.Sign)
+	}))
+	c.Now = func() time.Time { return now }
+
+	for range 2 {
+		if err := c.ClickWithKey(context.Background(), "DEVICE", key, "alice"); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(256 * time.Second)
+	}
+
+	// the signature depends on the time given by Now, not the wall clock
+	want0, _ := sign(key, time.Unix(0x12345678, 0))
+	want1, _ := sign(key, time.Unix(0x12345678+256, 0))
+	if want := []string{want0, want1}; !reflect.DeepEqual(signs, want) {
+		t.Errorf("unexpected signatures: %v != %v", signs, want)
+	}
+	if signs[0] == signs[1] {
+		t.Error("signature should change as the clock advances")
+	}
+}
+
+func TestClickWithKey(t *testing.T) {
+	const secretKey = "000102030405060708090a0b0c0d0e0f"
+	key, err := hex.DecodeString(secretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var bodies []commandRequest
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req commandRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		bodies = append(bodies, req)
+	}))
+	c.Now = func() time.Time { return time.Unix(0x12345678, 0) }
+
+	if err := c.Click(context.Background(), "DEVICE", secretKey, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClickWithKey(context.Background(), "DEVICE", key, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 2 || bodies[0] != bodies[1] {
+		t.Errorf("ClickWithKey should send the same request as Click: %+v", bodies)
+	}
+
+	for _, key := range [][]byte{key[:15], append(key, 0x10), nil} {
+		if err := c.ClickWithKey(context.Background(), "DEVICE", key, "alice"); !errors.Is(err, ErrInvalidSecretKey) {
+			t.Errorf("ErrInvalidSecretKey is expected for %d bytes key but got: %v", len(key), err)
+		}
+	}
+	if len(bodies) != 2 {
+		t.Errorf("invalid keys should not be sent: %d requests", len(bodies))
+	}
+}
+
+func TestNickname(t *testing.T) {
+	c := &Client{Nicknames: map[string]string{"DEVICE": "front door"}}
+	if got := c.Nickname("DEVICE"); got != "front door" {
+		t.Errorf("unexpected nickname: %s", got)
+	}
+	if got := c.Nickname("OTHER"); got != "OTHER" {
+		t.Errorf("UUID should be returned without nickname: %s", got)
+	}
+	if got := (&Client{}).Nickname("DEVICE"); got != "DEVICE" {
+		t.Errorf("UUID should be returned without Nicknames: %s", got)
+	}
+}
+
+func TestDerivedState(t *testing.T) {
+	settings := MechSettings{
+		LockRangeMin:   0,
+		LockRangeMax:   100,
+		UnlockRangeMin: 400,
+		UnlockRangeMax: 600,
+	}
+	tests := []struct {
+		position int
+		want     State
+	}{
+		{0, Locked},
+		{50, Locked},
+		{100, Locked},
+		{101, Moved},
+		{250, Moved},
+		{400, Unlocked},
+		{600, Unlocked},
+		{601, Moved},
+		{-1, Moved},
+	}
+	for _, tt := range tests {
+		status := StatusResponse{Position: tt.position, Status: Locked}
+		if got := status.DerivedState(settings); got != tt.want {
+			t.Errorf("unexpected state for position %d: %s != %s", tt.position, got, tt.want)
+		}
+	}
+}
+
+func TestHistoryDefault(t *testing.T) {
+	var requests []string
+	c := newTestClient(t, newHistoryServer(t, 5, 100, &requests))
+
+	if _, err := c.HistoryDefault(context.Background(), "DEVICE", 1); err != nil {
+		t.Fatal(err)
+	}
+	c.DefaultPageSize = 20
+	if _, err := c.HistoryDefault(context.Background(), "DEVICE", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"lg=50&page=1", "lg=20&page=0"}; !reflect.DeepEqual(requests, want) {
+		t.Errorf("unexpected requests: %v != %v", requests, want)
+	}
+}
+
+func TestAcceptLanguage(t *testing.T) {
+	var got []string
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("Accept-Language"))
+		_, _ = io.WriteString(w, `{}`)
+	}))
+
+	if _, err := c.Status(context.Background(), "DEVICE"); err != nil {
+		t.Fatal(err)
+	}
+	c.AcceptLanguage = "ja"
+	if _, err := c.Status(context.Background(), "DEVICE"); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"", "ja"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected Accept-Language headers: %q != %q", got, want)
+	}
+}
+
+func TestLastLockUnlock(t *testing.T) {
+	base := time.Unix(1600000000, 0)
+	pages := []HistoryPage{
+		{Type: TimeChanged, Timestamp: base.Add(5 * time.Minute)},
+		{Type: AutoLock, Timestamp: base.Add(4 * time.Minute)},
+		{Type: ManualUnlocked, Timestamp: base.Add(3 * time.Minute)},
+		{Type: BLELock, Timestamp: base.Add(2 * time.Minute)},
+		{Type: DriveUnlocked, Timestamp: base.Add(time.Minute)},
+	}
+
+	lastLock, lastUnlock, ok := LastLockUnlock(pages)
+	if !ok || !lastLock.Equal(base.Add(4*time.Minute)) || !lastUnlock.Equal(base.Add(3*time.Minute)) {
+		t.Errorf("unexpected result: (%s, %s, %t)", lastLock, lastUnlock, ok)
+	}
+
+	lastLock, lastUnlock, ok = LastLockUnlock(pages[3:4])
+	if !ok || !lastLock.Equal(base.Add(2*time.Minute)) || !lastUnlock.IsZero() {
+		t.Errorf("unexpected result for lock only: (%s, %s, %t)", lastLock, lastUnlock, ok)
+	}
+
+	if _, _, ok := LastLockUnlock(pages[:1]); ok {
+		t.Error("ok should be false without lock and unlock events")
+	}
+}
+
+func TestWithAPIKey(t *testing.T) {
+	var got []string
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get(DefaultAPIKeyHeader))
+		_, _ = io.WriteString(w, `{}`)
+	}))
+
+	if _, err := c.Status(WithAPIKey(context.Background(), "context-key"), "DEVICE"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Status(context.Background(), "DEVICE"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"context-key", testAPIKey}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected API keys: %q != %q", got, want)
+	}
+
+	// the API key in the context satisfies validation without Client.APIKey
+	c.APIKey = ""
+	if _, err := c.Status(WithAPIKey(context.Background(), "context-key"), "DEVICE"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	var verr *ValidationError
+	if _, err := c.Status(context.Background(), "DEVICE"); !errors.As(err, &verr) || verr.Field != "APIKey" {
+		t.Errorf("ValidationError for APIKey is expected but got: %v", err)
+	}
+}
+
+func TestAllHistoryTypes(t *testing.T) {
+	want := []HistoryType{
+		None,
+		BLELock,
+		BLEUnlock,
+		TimeChanged,
+		AutoLockUpdated,
+		MechSettingUpdated,
+		AutoLock,
+		ManualLocked,
+		ManualUnlocked,
+		ManualElse,
+		DriveLocked,
+		DriveUnlocked,
+		DriveFailed,
+		BLEAdvParameterUpdated,
+	}
+	if got := AllHistoryTypes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected history types:\n  got:  %v\n  want: %v", got, want)
+	}
+}
+
+func TestStateIsKnown(t *testing.T) {
+	var status StatusResponse
+	if err := json.Unmarshal([]byte(`{"CHSesame2Status": "calibrating"}`), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != "calibrating" {
+		t.Errorf("raw state should be kept: %q", status.Status)
+	}
+	if status.Status.IsKnown() {
+		t.Error("calibrating should not be known")
+	}
+
+	for _, state := range []State{Locked, Unlocked, Moved} {
+		if !state.IsKnown() {
+			t.Errorf("%s should be known", state)
+		}
+	}
+}
+
+func TestOnAttempt(t *testing.T) {
+	type attempt struct {
+		op      string
+		attempt int
+		status  int
+		failed  bool
+		elapsed time.Duration
+	}
+	var attempts []attempt
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	now := time.Unix(1600000000, 0)
+	c := &Client{
+		Endpoint: srv.URL,
+		APIKey:   testAPIKey,
+		// every call advances the clock by a second
+		Now: func() time.Time {
+			now = now.Add(time.Second)
+			return now
+		},
+		OnAttempt: func(op string, n int, status int, err error, elapsed time.Duration) {
+			attempts = append(attempts, attempt{op, n, status, err != nil, elapsed})
+		},
+	}
+
+	_, _ = c.Status(context.Background(), "DEVICE")
+	srv.Close()
+	_, _ = c.History(context.Background(), "DEVICE", 0, 10)
+
+	want := []attempt{
+		{op: "Status", attempt: 1, status: http.StatusServiceUnavailable, elapsed: time.Second},
+		{op: "History", attempt: 1, status: 0, failed: true, elapsed: time.Second},
+	}
+	if !reflect.DeepEqual(attempts, want) {
+		t.Errorf("unexpected attempts:\n  got:  %+v\n  want: %+v", attempts, want)
+	}
+}
+
+func TestLogLine(t *testing.T) {
+	status := StatusResponse{
+		Status:            Locked,
+		BatteryPercentage: 87,
+		BatteryVoltage:    5.9,
+		Position:          512,
+		Timestamp:         time.Date(2021, 6, 1, 12, 34, 56, 0, time.UTC),
+	}
+	if got, want := status.LogLine(), "state=locked battery=87% voltage=5.90 pos=512 ts=2021-06-01T12:34:56Z"; got != want {
+		t.Errorf("unexpected line:\n  got:  %s\n  want: %s", got, want)
+	}
+}
+
+func TestFilterHistory(t *testing.T) {
+	pages := []HistoryPage{
+		{RecordID: 1, Type: BLELock},
+		{RecordID: 2, Type: ManualLocked},
+		{RecordID: 3, Type: AutoLock},
+		{RecordID: 4, Type: ManualElse},
+		{RecordID: 5, Type: ManualUnlocked},
+		{RecordID: 6, Type: DriveFailed},
+	}
+	recordIDs := func(pages []HistoryPage) []int {
+		var ids []int
+		for _, page := range pages {
+			ids = append(ids, page.RecordID)
+		}
+		return ids
+	}
+
+	if got, want := recordIDs(FilterHistory(pages, AutoLock, DriveFailed)), []int{3, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected filtered records: %v != %v", got, want)
+	}
+	if got, want := recordIDs(ManualOperations(pages)), []int{2, 4, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected manual operations: %v != %v", got, want)
+	}
+	if got := FilterHistory(pages); got != nil {
+		t.Errorf("nothing should match without types: %v", got)
+	}
+}
+
+func TestEstimateHistoryPages(t *testing.T) {
+	tests := []struct {
+		totalRecords, pageSize int
+		want                   int
+	}{
+		{0, 50, 1},
+		{49, 50, 1},
+		{50, 50, 2}, // the empty page after the full one is needed to stop paging
+		{120, 50, 3},
+		{150, 50, 4},
+		{-1, 50, 0},
+		{10, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := EstimateHistoryPages(tt.totalRecords, tt.pageSize); got != tt.want {
+			t.Errorf("unexpected pages for %d records by %d: %d != %d", tt.totalRecords, tt.pageSize, got, tt.want)
+		}
+	}
+}
+
+func TestBatteryDelta(t *testing.T) {
+	base := time.Unix(1600000000, 0)
+	at := func(percent int, d time.Duration) *StatusResponse {
+		return &StatusResponse{BatteryPercentage: percent, Timestamp: base.Add(d)}
+	}
+	tests := []struct {
+		name           string
+		earlier, later *StatusResponse
+		want           int
+	}{
+		{"increasing", at(40, 0), at(100, time.Hour), 60},
+		{"decreasing", at(80, 0), at(75, time.Hour), -5},
+		{"swapped", at(75, time.Hour), at(80, 0), -5},
+		{"unchanged", at(80, 0), at(80, time.Hour), 0},
+	}
+	for _, tt := range tests {
+		if got := BatteryDelta(tt.earlier, tt.later); got != tt.want {
+			t.Errorf("unexpected delta for %s: %d != %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBinaryLocked(t *testing.T) {
+	tests := []struct {
+		state                   State
+		want, wantMovedAsLocked bool
+	}{
+		{Locked, true, true},
+		{Unlocked, false, false},
+		{Moved, false, true},
+		{"calibrating", false, false},
+	}
+	for _, tt := range tests {
+		status := StatusResponse{Status: tt.state}
+		if got := status.BinaryLocked(); got != tt.want {
+			t.Errorf("unexpected BinaryLocked for %s: %t != %t", tt.state, got, tt.want)
+		}
+		if got := status.BinaryLockedOr(true); got != tt.wantMovedAsLocked {
+			t.Errorf("unexpected BinaryLockedOr(true) for %s: %t != %t", tt.state, got, tt.wantMovedAsLocked)
+		}
+	}
+}
+
+func TestEqualUUID(t *testing.T) {
+	const uuid = "3D2C8E7A-1B4F-4C6D-9E0A-5F8B7C6D5E4F"
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{uuid, uuid, true},
+		{uuid, strings.ToLower(uuid), true},
+		{uuid, "{" + uuid + "}", true},
+		{uuid, "  " + uuid + "\n", true},
+		{" {" + strings.ToLower(uuid) + "} ", "{ " + uuid + " }", true},
+		{uuid, "3D2C8E7A-1B4F-4C6D-9E0A-5F8B7C6D5E40", false},
+		{uuid, "", false},
+	}
+	for _, tt := range tests {
+		if got := EqualUUID(tt.a, tt.b); got != tt.want {
+			t.Errorf("unexpected EqualUUID(%q, %q): %t != %t", tt.a, tt.b, got, tt.want)
+		}
+		if got := EqualUUID(tt.b, tt.a); got != tt.want {
+			t.Errorf("EqualUUID should be symmetric: EqualUUID(%q, %q) = %t", tt.b, tt.a, got)
+		}
+	}
+}
+
+func TestDistinctHistoryTypes(t *testing.T) {
+	pages := []HistoryPage{
+		{Type: DriveUnlocked},
+		{Type: BLELock},
+		{Type: DriveFailed},
+		{Type: DriveUnlocked},
+		{Type: BLELock},
+		{Type: AutoLock},
+	}
+	if got, want := DistinctHistoryTypes(pages), []HistoryType{BLELock, AutoLock, DriveUnlocked, DriveFailed}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected types: %v != %v", got, want)
+	}
+	if got := DistinctHistoryTypes(nil); len(got) != 0 {
+		t.Errorf("no types are expected but got: %v", got)
+	}
+}
@@ -0,0 +1,178 @@
+// Package sesametest provides a recorder and a replayer of API interactions
+// for running tests offline against realistic payloads.
+//
+// Both Recorder and Replayer are http.RoundTripper, so they can be used as the Transport of Client.HTTPClient.
+package sesametest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Interaction is a pair of recorded request and response.
+// Request headers are not recorded so that the API key never leaks into cassettes,
+// and sensitive response headers are redacted for the same reason.
+type Interaction struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	RequestBody string      `json:"requestBody,omitempty"`
+	StatusCode  int         `json:"statusCode"`
+	Header      http.Header `json:"header,omitempty"`
+	// Response body, which is base64 encoded in cassettes so that compressed bodies survive.
+	Body []byte `json:"body"`
+}
+
+// Redacted is recorded instead of the values of sensitive response headers.
+const Redacted = "REDACTED"
+
+// sensitiveHeaders are the response headers always redacted by Recorder.
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"WWW-Authenticate",
+	"Proxy-Authenticate",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+}
+
+// Recorder records the interactions made through it.
+type Recorder struct {
+	// Transport used for actual requests. http.DefaultTransport will be used when this value is nil.
+	Transport http.RoundTripper
+	// Additional response headers to be redacted, e.g. the custom API key header of Client.
+	RedactHeaders []string
+
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("reading request body: %w", err)
+		}
+		_ = req.Body.Close()
+		reqBody = b
+		req.Body = io.NopCloser(bytes.NewReader(b))
+	}
+
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, Interaction{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		RequestBody: string(reqBody),
+		StatusCode:  resp.StatusCode,
+		Header:      r.redact(resp.Header),
+		Body:        body,
+	})
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+// redact returns a copy of header whose sensitive values are replaced with Redacted.
+func (r *Recorder) redact(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, names := range [][]string{sensitiveHeaders, r.RedactHeaders} {
+		for _, name := range names {
+			values := redacted.Values(name)
+			for i := range values {
+				values[i] = Redacted
+			}
+		}
+	}
+	return redacted
+}
+
+// Save writes the recorded interactions to the cassette file.
+func (r *Recorder) Save(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, err := json.MarshalIndent(r.interactions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding cassette: %w", err)
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		return fmt.Errorf("writing cassette: %w", err)
+	}
+	return nil
+}
+
+// Replayer replays the recorded interactions in order.
+type Replayer struct {
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+// Load reads the cassette file written by Recorder.Save.
+func Load(path string) (*Replayer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading cassette: %w", err)
+	}
+
+	var interactions []Interaction
+	if err := json.Unmarshal(b, &interactions); err != nil {
+		return nil, fmt.Errorf("decoding cassette: %w", err)
+	}
+
+	return &Replayer{interactions: interactions}, nil
+}
+
+// RoundTrip returns the response of the first unused interaction matching the method and URL of req.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_, _ = io.Copy(io.Discard, req.Body)
+		_ = req.Body.Close()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.interactions {
+		if interaction.Method != req.Method || interaction.URL != req.URL.String() {
+			continue
+		}
+		r.interactions = append(r.interactions[:i:i], r.interactions[i+1:]...)
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.StatusCode, http.StatusText(interaction.StatusCode)),
+			StatusCode:    interaction.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        interaction.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(interaction.Body)),
+			ContentLength: int64(len(interaction.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("no recorded interaction for %s %s", req.Method, req.URL)
+}
@@ -0,0 +1,138 @@
+package sesametest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	const body = `{"batteryPercentage": 100}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Add("Set-Cookie", "session=secret1")
+		w.Header().Add("Set-Cookie", "token=secret2")
+		w.Header().Set("X-Echo-Key", "secret3")
+		_, _ = io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	recorder := &Recorder{RedactHeaders: []string{"X-Echo-Key"}}
+
+	resp, err := (&http.Client{Transport: recorder}).Get(srv.URL + "/DEVICE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(got) != body {
+		t.Errorf("unexpected recorded body: %s", got)
+	}
+	if resp.Header.Get("Set-Cookie") != "session=secret1" {
+		t.Error("the live response should not be redacted")
+	}
+
+	if err := recorder.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(saved), "secret") {
+		t.Errorf("cassette contains a secret:\n%s", saved)
+	}
+
+	replayer, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = (&http.Client{Transport: replayer}).Get(srv.URL + "/DEVICE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(got) != body {
+		t.Errorf("unexpected replayed body: %s", got)
+	}
+	if resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected Content-Type: %s", resp.Header.Get("Content-Type"))
+	}
+	for _, name := range []string{"Set-Cookie", "X-Echo-Key"} {
+		for _, v := range resp.Header.Values(name) {
+			if v != Redacted {
+				t.Errorf("%s is not redacted: %s", name, v)
+			}
+		}
+	}
+
+	if _, err := (&http.Client{Transport: replayer}).Get(srv.URL + "/DEVICE"); err == nil || !strings.Contains(err.Error(), "no recorded interaction") {
+		t.Errorf("replayed interaction should not be reused: %v", err)
+	}
+}
+
+func TestRecordAndReplayCompressed(t *testing.T) {
+	const body = `{"batteryPercentage": 100, "CHSesame2Status": "locked"}`
+	var gzipped bytes.Buffer
+	zw := gzip.NewWriter(&gzipped)
+	_, _ = io.WriteString(zw, body)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		_, _ = w.Write(gzipped.Bytes())
+	}))
+	defer srv.Close()
+
+	// the transport doesn't decompress the body when Accept-Encoding is set explicitly
+	get := func(transport http.RoundTripper) []byte {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/DEVICE", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	recorder := &Recorder{}
+	if got := get(recorder); string(got) != body {
+		t.Errorf("unexpected recorded body: %s", got)
+	}
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	if err := recorder.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	replayer, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := get(replayer); string(got) != body {
+		t.Errorf("unexpected replayed body: %s", got)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// VerifyWebhookSignature reports whether signature is valid for the webhook body.
+// The candyhouse API doesn't define webhook signatures, so this verifies
+// the hex encoded HMAC-SHA256 of the body keyed by secret, which is commonly used by signing proxies.
+// false is always returned when secret is empty, since anyone can sign with the empty key.
+func VerifyWebhookSignature(body []byte, signature, secret string) bool {
+	if secret == "" {
+		return false
+	}
+
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(sig, mac.Sum(nil))
+}
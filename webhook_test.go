@@ -0,0 +1,41 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	const (
+		body = `{"CHSesame2Status":"locked"}`
+		// echo -n '{"CHSesame2Status":"locked"}' | openssl dgst -sha256 -hmac secret
+		signature = "01416c1ec7e7cced28f0c4a00c0901a4168c4b1cafb50155571815db5df345b7"
+	)
+	// a valid signature with the empty key must be rejected as well
+	mac := hmac.New(sha256.New, nil)
+	mac.Write([]byte(body))
+	emptyKeySignature := hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+		secret    string
+		want      bool
+	}{
+		{"valid", body, signature, "secret", true},
+		{"tampered body", `{"CHSesame2Status":"unlocked"}`, signature, "secret", false},
+		{"wrong secret", body, signature, "other", false},
+		{"not hex", body, "not hex", "secret", false},
+		{"empty secret", body, emptyKeySignature, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyWebhookSignature([]byte(tt.body), tt.signature, tt.secret); got != tt.want {
+				t.Errorf("unexpected result: %t != %t", got, tt.want)
+			}
+		})
+	}
+}